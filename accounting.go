@@ -0,0 +1,62 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// count each notification of the informer about a matching event
+	AccountingNotifications = "notifications"
+	// count the occurrences of a matching event, i.e. the increase of its count
+	AccountingOccurrences = "occurrences"
+)
+
+// eventOccurrences returns how often an event occurred. Events created through
+// the events.k8s.io API count repetitions in Series.Count instead of Count.
+func eventOccurrences(event *v1.Event) int {
+	switch {
+	case event.Series != nil && event.Series.Count > 0:
+		return int(event.Series.Count)
+	case event.Count > 0:
+		return int(event.Count)
+	}
+	return 1
+}
+
+// occurrencesSince returns how often an event occurred since its previous
+// version. A previous version of a different object, or with a higher count,
+// means that the event has been recreated.
+func occurrencesSince(oldEvent, event *v1.Event) int {
+	if oldEvent == nil || oldEvent.UID != event.UID {
+		return eventOccurrences(event)
+	}
+	return occurrencesAfter(eventOccurrences(oldEvent), event)
+}
+
+// occurrencesAfter returns how often an event occurred since it had been seen
+// with the given number of occurrences, e.g. before a restart.
+func occurrencesAfter(previous int, event *v1.Event) int {
+	occurrences := eventOccurrences(event)
+	if delta := occurrences - previous; delta >= 0 {
+		return delta
+	}
+	return occurrences
+}
+
+func occurrencesLookup(ctx *LookupContext) (float64, error) { //nolint:unparam
+	return float64(ctx.occurrences), nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOccurrenceAccounting(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: backoff_notifications
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+- name: backoff_occurrences
+  accounting: occurrences
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+	router := &EventRouter{Config: config}
+
+	oldEvent := v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1234"}, Reason: "BackOff", Count: 3}
+	require.Equal(t, []FilterMatch{
+		{Name: "backoff_notifications", Labels: map[string]string{}, Value: 1},
+		{Name: "backoff_occurrences", Labels: map[string]string{}, Value: 3},
+	}, LogEvent(&oldEvent, router))
+
+	newEvent := oldEvent
+	newEvent.Count = 8
+	require.Equal(t, []FilterMatch{
+		{Name: "backoff_notifications", Labels: map[string]string{}, Value: 1},
+		{Name: "backoff_occurrences", Labels: map[string]string{}, Value: 5},
+	}, logEvent(&newEvent, occurrencesSince(&oldEvent, &newEvent), false, router))
+
+	// an update without new occurrences is not counted
+	require.Equal(t, []FilterMatch{
+		{Name: "backoff_notifications", Labels: map[string]string{}, Value: 1},
+	}, logEvent(&newEvent, occurrencesSince(&newEvent, &newEvent), false, router))
+}
+
+func TestEventOccurrences(t *testing.T) {
+	require.Equal(t, 1, eventOccurrences(&v1.Event{}))
+	require.Equal(t, 4, eventOccurrences(&v1.Event{Count: 4}))
+	require.Equal(t, 6, eventOccurrences(&v1.Event{Series: &v1.EventSeries{Count: 6}}))
+
+	oldEvent := &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1234"}, Series: &v1.EventSeries{Count: 6}}
+	require.Equal(t, 3, occurrencesSince(oldEvent, &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1234"}, Series: &v1.EventSeries{Count: 9}}))
+	// recreated events are counted completely
+	require.Equal(t, 2, occurrencesSince(oldEvent, &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "5678"}, Count: 2}))
+	require.Equal(t, 2, occurrencesSince(oldEvent, &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1234"}, Series: &v1.EventSeries{Count: 2}}))
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// timeout of conditions without explicit timeout
+const defaultConditionTimeout = time.Hour
+
+// ConditionConfig turns a metric into a gauge of the objects for which a
+// condition is active. The condition is raised by events matching Raise and
+// resolved by events about the same object matching Resolve, or after the
+// timeout.
+type ConditionConfig struct {
+	Raise   []EventMatcher `yaml:"raise"`
+	Resolve []EventMatcher `yaml:"resolve"`
+	Timeout time.Duration  `yaml:"timeout"`
+	resolve *matcherSet
+}
+
+// compileCondition validates the condition of a metric and compiles its
+// resolve matchers. The raise matchers are compiled as the metric's matchers.
+func compileCondition(metric *MetricConfig) error {
+	condition := metric.Condition
+	if len(metric.EventMatcher) > 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: Can't use event_matcher together with a condition, use raise instead", metric.Name)
+	}
+	if metric.Type != "" || metric.Value != "" || metric.Accounting != "" || metric.Window != 0 || metric.Buckets != nil || metric.Objectives != nil || metric.NativeHistogramBucketFactor != 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: Can't use type, value, accounting, window or histogram options together with a condition", metric.Name)
+	}
+	if len(condition.Raise) == 0 || len(condition.Resolve) == 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: condition needs raise and resolve matchers", metric.Name)
+	}
+	switch {
+	case condition.Timeout < 0:
+		return fmt.Errorf("configuration for metric '%s' invalid: condition timeout must not be negative", metric.Name)
+	case condition.Timeout == 0:
+		condition.Timeout = defaultConditionTimeout
+	}
+
+	var err error
+	condition.resolve, err = newMatcherSet(metric, condition.Resolve)
+	if err != nil {
+		return fmt.Errorf("configuration for metric '%s' invalid: resolve matchers invalid: %w", metric.Name, err)
+	}
+	return nil
+}
+
+// involvedObjectKey identifies the object an event refers to. Not all event
+// sources set the UID of the involved object.
+func involvedObjectKey(event *v1.Event) string {
+	if event.InvolvedObject.UID != "" {
+		return string(event.InvolvedObject.UID)
+	}
+	return strings.Join([]string{event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name}, "/")
+}
+
+// conditionVec is a collector which exports the number of objects with an
+// active condition per label set. The labels of an object are taken from the
+// event which raised the condition. Series stay at 0 for the timeout after
+// their last object has been resolved, and are removed afterwards.
+type conditionVec struct {
+	desc    *prometheus.Desc
+	labels  []string
+	timeout time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	active map[string]*activeCondition
+	// active conditions ordered by when they have been raised, i.e. by expiry
+	queue  conditionQueue
+	series map[string]*conditionSeries
+}
+
+type activeCondition struct {
+	object string
+	series *conditionSeries
+	raised time.Time
+	// position in the queue
+	index int
+}
+
+// conditionQueue is a heap of active conditions, the earliest raised first.
+type conditionQueue []*activeCondition
+
+func (q conditionQueue) Len() int           { return len(q) }
+func (q conditionQueue) Less(i, j int) bool { return q[i].raised.Before(q[j].raised) }
+
+func (q conditionQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *conditionQueue) Push(x interface{}) {
+	condition := x.(*activeCondition)
+	condition.index = len(*q)
+	*q = append(*q, condition)
+}
+
+func (q *conditionQueue) Pop() interface{} {
+	old := *q
+	condition := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return condition
+}
+
+type conditionSeries struct {
+	key         string
+	labelValues []string
+	active      int
+	// when the last object of the series has been resolved
+	inactiveSince time.Time
+}
+
+func newConditionVec(name, help string, labels []string, timeout time.Duration) *conditionVec {
+	return &conditionVec{
+		desc:    prometheus.NewDesc(name, help, labels, nil),
+		labels:  labels,
+		timeout: timeout,
+		now:     time.Now,
+		active:  make(map[string]*activeCondition),
+		series:  make(map[string]*conditionSeries),
+	}
+}
+
+// Update raises or resolves the condition for the object of a match.
+func (v *conditionVec) Update(match FilterMatch) error {
+	if match.Resolve {
+		v.Resolve(match.Object)
+		return nil
+	}
+	return v.Raise(match.Object, match.Labels)
+}
+
+// Raise activates the condition for an object. Raising an active condition
+// again restarts its timeout.
+func (v *conditionVec) Raise(object string, labels map[string]string) error {
+	if len(labels) != len(v.labels) {
+		return fmt.Errorf("expected labels %v, got %v", v.labels, labels)
+	}
+	labelValues := make([]string, len(v.labels))
+	for i, name := range v.labels {
+		value, found := labels[name]
+		if !found {
+			return fmt.Errorf("label '%s' missing", name)
+		}
+		labelValues[i] = value
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	v.expire(now)
+	if condition, found := v.active[object]; found {
+		if condition.series.key == key {
+			condition.raised = now
+			heap.Fix(&v.queue, condition.index)
+			return nil
+		}
+		v.deactivate(object, now)
+	}
+	series, found := v.series[key]
+	if !found {
+		series = &conditionSeries{key: key, labelValues: labelValues}
+		v.series[key] = series
+	}
+	series.active++
+	condition := &activeCondition{object: object, series: series, raised: now}
+	v.active[object] = condition
+	heap.Push(&v.queue, condition)
+	return nil
+}
+
+// Resolve deactivates the condition for an object, if it is active.
+func (v *conditionVec) Resolve(object string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	v.expire(now)
+	if _, found := v.active[object]; found {
+		v.deactivate(object, now)
+	}
+}
+
+func (v *conditionVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+func (v *conditionVec) Collect(ch chan<- prometheus.Metric) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	v.expire(now)
+	for key, series := range v.series {
+		if series.active == 0 && now.Sub(series.inactiveSince) > v.timeout {
+			delete(v.series, key)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(v.desc, prometheus.GaugeValue, float64(series.active), series.labelValues...)
+	}
+}
+
+// expire resolves the conditions which have been raised longer than the
+// timeout ago. They are at the front of the queue, so the conditions which
+// are still active don't have to be looked at.
+func (v *conditionVec) expire(now time.Time) {
+	for len(v.queue) > 0 && now.Sub(v.queue[0].raised) > v.timeout {
+		condition := v.queue[0]
+		v.deactivate(condition.object, condition.raised.Add(v.timeout))
+	}
+}
+
+func (v *conditionVec) deactivate(object string, now time.Time) {
+	condition := v.active[object]
+	series := condition.series
+	delete(v.active, object)
+	heap.Remove(&v.queue, condition.index)
+	series.active--
+	if series.active == 0 {
+		series.inactiveSince = now
+	}
+}
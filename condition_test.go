@@ -0,0 +1,118 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+// conditionValues collects the current values of a conditionVec by namespace label.
+func conditionValues(t *testing.T, vec *conditionVec) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 10)
+	vec.Collect(ch)
+	close(ch)
+	values := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+		values[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestConditionMatches(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: mount_problems
+  condition:
+    raise:
+    - key: Reason
+      expr: FailedMount
+    resolve:
+    - key: Reason
+      expr: ^(SuccessfulMountVolume|Started)$
+  labels:
+    namespace: InvolvedObject.Namespace
+`))
+	require.NoError(t, err)
+	router := &EventRouter{Config: config}
+
+	pod := v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "app", UID: "1234"}
+	require.Equal(t, []FilterMatch{
+		{Name: "mount_problems", Labels: map[string]string{"namespace": "default"}, Value: 1, Object: "1234"},
+	}, LogEvent(&v1.Event{Reason: "FailedMount", InvolvedObject: pod}, router))
+	require.Equal(t, []FilterMatch{
+		{Name: "mount_problems", Object: "1234", Resolve: true},
+	}, LogEvent(&v1.Event{Reason: "Started", InvolvedObject: pod}, router))
+	require.Empty(t, LogEvent(&v1.Event{Reason: "Pulled", InvolvedObject: pod}, router))
+
+	pod.UID = ""
+	require.Equal(t, "Pod/default/app", LogEvent(&v1.Event{Reason: "Started", InvolvedObject: pod}, router)[0].Object)
+}
+
+func TestConditionVec(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	vec := newConditionVec("mount_problems", "help", []string{"namespace"}, time.Hour)
+	vec.now = func() time.Time { return now }
+
+	require.NoError(t, vec.Raise("pod-1", map[string]string{"namespace": "default"}))
+	require.NoError(t, vec.Raise("pod-2", map[string]string{"namespace": "default"}))
+	require.NoError(t, vec.Raise("pod-2", map[string]string{"namespace": "default"}))
+	require.NoError(t, vec.Raise("pod-3", map[string]string{"namespace": "kube-system"}))
+	require.Equal(t, map[string]float64{"default": 2, "kube-system": 1}, conditionValues(t, vec))
+
+	vec.Resolve("pod-3")
+	vec.Resolve("pod-4")
+	require.Equal(t, map[string]float64{"default": 2, "kube-system": 0}, conditionValues(t, vec))
+
+	// raising again restarts the timeout of pod-2 only
+	now = now.Add(40 * time.Minute)
+	require.NoError(t, vec.Raise("pod-2", map[string]string{"namespace": "default"}))
+	now = now.Add(30 * time.Minute)
+	require.Equal(t, map[string]float64{"default": 1}, conditionValues(t, vec))
+
+	now = now.Add(45 * time.Minute)
+	require.Equal(t, map[string]float64{"default": 0}, conditionValues(t, vec))
+	now = now.Add(2 * time.Hour)
+	require.Empty(t, conditionValues(t, vec))
+
+	// an object raised with other labels moves to their series
+	require.NoError(t, vec.Raise("pod-1", map[string]string{"namespace": "default"}))
+	require.NoError(t, vec.Raise("pod-5", map[string]string{"namespace": "kube-system"}))
+	require.NoError(t, vec.Raise("pod-1", map[string]string{"namespace": "kube-system"}))
+	require.Equal(t, map[string]float64{"default": 0, "kube-system": 2}, conditionValues(t, vec))
+	require.Len(t, vec.queue, 2)
+	now = now.Add(2 * time.Hour)
+	require.Equal(t, map[string]float64{"kube-system": 0}, conditionValues(t, vec))
+	require.Empty(t, vec.queue)
+}
+
+func TestConfigErrorCondition(t *testing.T) {
+	for testConfig, expected := range map[string]string{
+		"metrics:\n- name: c\n  condition:\n    raise:\n    - key: Reason\n":                                                                     "configuration for metric 'c' invalid: condition needs raise and resolve matchers",
+		"metrics:\n- name: c\n  type: gauge\n  condition:\n    raise:\n    - key: Reason\n    resolve:\n    - key: Reason\n":                     "configuration for metric 'c' invalid: Can't use type, value, accounting, window or histogram options together with a condition",
+		"metrics:\n- name: c\n  event_matcher:\n  - key: Reason\n  condition:\n    raise:\n    - key: Reason\n    resolve:\n    - key: Reason\n": "configuration for metric 'c' invalid: Can't use event_matcher together with a condition, use raise instead",
+	} {
+		_, err := NewConfig(bytes.NewBufferString(testConfig))
+		require.EqualError(t, err, expected)
+	}
+}
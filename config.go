@@ -15,33 +15,164 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
 )
 
 var (
-	labelSubMatchRE = regexp.MustCompile(`^(.*)\[([0-9])\]$`)
+	// matches submatches by index or by name, e.g. `Message[1]` or `Message[volume]`
+	labelSubMatchRE = regexp.MustCompile(`^(.*)\[(\w+)\]$`)
 )
 
-type LookupFunc = func(event *v1.Event, matches map[string][]string) (string, error)
+// LookupContext carries everything a key or label lookup may refer to while
+// a single event is evaluated against a metric.
+type LookupContext struct {
+	Event *v1.Event
+	// submatches of the event matchers by key
+	Matches map[string][]string
+	// element of the list the metric is expanded over, nil if it is not expanded
+	Item interface{}
+	// result of the severity rules, nil while they are evaluated
+	Classification *Classification
+
+	metric        *MetricConfig
+	objects       *eventObjects
+	occurrences   int
+	messageFields map[string]string
+	messageErr    error
+}
+
+type LookupFunc = func(ctx *LookupContext) (string, error)
+
+// ItemsFunc returns the list a metric is expanded over, one series per element.
+type ItemsFunc = func(ctx *LookupContext) ([]interface{}, error)
+
+type ValuesFunc = func(ctx *LookupContext) ([]string, error)
+
+type EventMatcher struct {
+	Key  string `yaml:"key"`
+	Expr string `yaml:"expr"`
+}
+
+// matcherSet is a list of event matchers compiled for evaluation. It matches
+// an event if all of its matchers do.
+type matcherSet struct {
+	matchers     []EventMatcher
+	regexMap     map[string]*regexp.Regexp
+	keyLookupMap map[string]LookupFunc
+	// keys with several values per event, which match if any value does
+	keyValuesMap map[string]ValuesFunc
+}
+
+// newMatcherSet compiles a list of event matchers. The metric they belong to
+// is nil for matchers outside of metrics, e.g. in severity rules. Matchers of
+// keys parsed from the message are evaluated last, so that only events which
+// match otherwise are parsed.
+func newMatcherSet(metric *MetricConfig, matchers []EventMatcher) (*matcherSet, error) {
+	matchers = append([]EventMatcher(nil), matchers...)
+	sort.SliceStable(matchers, func(i, j int) bool {
+		return !isParsedKey(matchers[i].Key) && isParsedKey(matchers[j].Key)
+	})
+	set := &matcherSet{
+		matchers:     matchers,
+		regexMap:     make(map[string]*regexp.Regexp, len(matchers)),
+		keyLookupMap: make(map[string]LookupFunc, len(matchers)),
+		keyValuesMap: make(map[string]ValuesFunc),
+	}
+	for _, matcher := range matchers {
+		r, err := regexp.Compile(matcher.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("match expression for key %s invalid: %w", matcher.Key, err)
+		}
+		if _, found := set.regexMap[matcher.Key]; found {
+			return nil, fmt.Errorf("Multiple matchers for key '%s'", matcher.Key)
+		}
+		set.regexMap[matcher.Key] = r
+		if isSchedulingReasonField(matcher.Key) {
+			set.keyValuesMap[matcher.Key] = newSchedulingReasonValues(matcher.Key)
+			continue
+		}
+		set.keyLookupMap[matcher.Key], err = newKeyLookup(metric, matcher.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+const (
+	OnLookupErrorDrop       = "drop"
+	OnLookupErrorDefault    = "default"
+	OnLookupErrorCountError = "count_error"
+
+	// label value used for failed lookups with OnLookupErrorCountError
+	lookupErrorValue = "lookup_error"
+)
+
+// LabelConfig describes how the value of a label is looked up. It is given
+// as a single source, as a list of sources which are tried in order, or as
+// a mapping which additionally allows a default and a transformation pipeline.
+type LabelConfig struct {
+	Source    string            `yaml:"source"`
+	Sources   []string          `yaml:"sources"`
+	Default   string            `yaml:"default"`
+	Transform []TransformConfig `yaml:"transform"`
+}
+
+func (l *LabelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&l.Source); err == nil {
+		return nil
+	}
+	if err := unmarshal(&l.Sources); err == nil {
+		return nil
+	}
+	type plain LabelConfig
+	return unmarshal((*plain)(l))
+}
+
+type MetricConfig struct {
+	Name                        string                 `yaml:"name"`
+	EventMatcher                []EventMatcher         `yaml:"event_matcher"`
+	Labels                      map[string]LabelConfig `yaml:"labels"`
+	Expand                      *ExpandConfig          `yaml:"expand"`
+	OnLookupError               string                 `yaml:"on_lookup_error"`
+	MessageFormat               string                 `yaml:"message_format"`
+	Type                        string                 `yaml:"type"`
+	Accounting                  string                 `yaml:"accounting"`
+	MaxAge                      time.Duration          `yaml:"max_age"`
+	Window                      time.Duration          `yaml:"window"`
+	Condition                   *ConditionConfig       `yaml:"condition"`
+	Latency                     *LatencyConfig         `yaml:"latency"`
+	Distinct                    *DistinctConfig        `yaml:"distinct"`
+	Value                       string                 `yaml:"value"`
+	Buckets                     []float64              `yaml:"buckets"`
+	Objectives                  map[float64]float64    `yaml:"objectives"`
+	NativeHistogramBucketFactor float64                `yaml:"native_histogram_bucket_factor"`
+	matchers                    *matcherSet
+	labelLookupMap              map[string]LookupFunc
+	labelTransformMap           map[string][]TransformFunc
+	itemsLookup                 ItemsFunc
+	valueLookup                 ValueFunc
+	expandRegex                 *regexp.Regexp
+	lookupTables                map[string]*LookupTable
+	messageParser               MessageParser
+}
 
 type Config struct {
-	Metrics []struct {
-		Name         string `yaml:"name"`
-		EventMatcher []struct {
-			Key  string `yaml:"key"`
-			Expr string `yaml:"expr"`
-		} `yaml:"event_matcher"`
-		Labels         map[string]string `yaml:"labels"`
-		regexMap       map[string]*regexp.Regexp
-		labelLookupMap map[string]LookupFunc
-	} `yaml:"metrics"`
+	LookupTables  []LookupTableConfig `yaml:"lookup_tables"`
+	SeverityRules []SeverityRule      `yaml:"severity_rules"`
+	Metrics       []MetricConfig      `yaml:"metrics"`
+	lookupTables  map[string]*LookupTable
 }
 
 func NewConfig(reader io.Reader) (*Config, error) {
@@ -49,56 +180,333 @@ func NewConfig(reader io.Reader) (*Config, error) {
 	if err := yaml.NewDecoder(reader).Decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
-	for i, metric := range config.Metrics {
-		config.Metrics[i].regexMap = make(map[string]*regexp.Regexp, len(metric.EventMatcher))
-		for _, matcher := range metric.EventMatcher {
-			r, err := regexp.Compile(matcher.Expr)
-			if err != nil {
-				return nil, fmt.Errorf("configuration for metric '%s' invalid: match expression for key %s invalid: %w", metric.Name, matcher.Key, err)
+
+	config.lookupTables = make(map[string]*LookupTable, len(config.LookupTables))
+	for _, tableConfig := range config.LookupTables {
+		table, err := newLookupTable(tableConfig)
+		if err != nil {
+			return nil, fmt.Errorf("configuration invalid: %w", err)
+		}
+		if _, found := config.lookupTables[tableConfig.Name]; found {
+			return nil, fmt.Errorf("configuration invalid: Multiple lookup tables named '%s'", tableConfig.Name)
+		}
+		config.lookupTables[tableConfig.Name] = table
+	}
+
+	for i := range config.SeverityRules {
+		if err := config.SeverityRules[i].compile(); err != nil {
+			return nil, fmt.Errorf("configuration for severity rule %d invalid: %w", i+1, err)
+		}
+	}
+
+	for i := range config.Metrics {
+		metric := &config.Metrics[i]
+		metric.lookupTables = config.lookupTables
+		if metric.MessageFormat != "" {
+			var found bool
+			metric.messageParser, found = messageParsers[metric.MessageFormat]
+			if !found {
+				return nil, fmt.Errorf("configuration for metric '%s' invalid: Unknown message_format '%s'", metric.Name, metric.MessageFormat)
 			}
-			if _, found := config.Metrics[i].regexMap[matcher.Key]; found {
-				return nil, fmt.Errorf("configuration for metric '%s' invalid: Multiple matchers for key '%s'", metric.Name, matcher.Key)
+		}
+
+		eventMatchers := metric.EventMatcher
+		switch {
+		case countSet(metric.Condition != nil, metric.Latency != nil, metric.Distinct != nil) > 1:
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't use more than one of condition, latency and distinct", metric.Name)
+		case metric.Condition != nil:
+			if err := compileCondition(metric); err != nil {
+				return nil, err
+			}
+			eventMatchers = metric.Condition.Raise
+		case metric.Latency != nil:
+			if err := compileLatency(metric); err != nil {
+				return nil, err
+			}
+			eventMatchers = metric.Latency.End
+		}
+		var err error
+		metric.matchers, err = newMatcherSet(metric, eventMatchers)
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: %w", metric.Name, err)
+		}
+
+		if metric.MaxAge < 0 {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: max_age must not be negative", metric.Name)
+		}
+
+		switch metric.OnLookupError {
+		case "":
+			metric.OnLookupError = OnLookupErrorDrop
+		case OnLookupErrorDrop, OnLookupErrorDefault, OnLookupErrorCountError:
+		default:
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Unknown on_lookup_error policy '%s'", metric.Name, metric.OnLookupError)
+		}
+
+		if metric.Expand != nil {
+			metric.itemsLookup, err = newItemsLookup(metric)
+			if err != nil {
+				return nil, err
 			}
-			config.Metrics[i].regexMap[matcher.Key] = r
 		}
-		config.Metrics[i].labelLookupMap = make(map[string]func(*v1.Event, map[string][]string) (string, error), len(metric.Labels))
 
 		// create lookup map for label values
-		for key, l := range metric.Labels {
-			labelSpec := l // local copy of l, needed for closures
-			if strings.HasPrefix(labelSpec, PodVirtualTypePrefix) {
-				config.Metrics[i].labelLookupMap[key] = func(event *v1.Event, _ map[string][]string) (string, error) {
-					pod, err := getPodObjectForEvent(event)
-					if err != nil {
-						return "", err
-					}
-					return GetValueFromStruct(pod, strings.TrimPrefix(labelSpec, PodVirtualTypePrefix))
-				}
-			} else {
-				if matches := labelSubMatchRE.FindStringSubmatch(labelSpec); matches != nil {
-					label := matches[1]
-					submatch, err := strconv.Atoi(matches[2])
-					if err != nil {
-						return nil, fmt.Errorf("failed to parse label %s for metric %s: %w", labelSpec, metric.Name, err)
-					}
-					re, found := config.Metrics[i].regexMap[label]
-					if !found {
-						return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't use a submatch for key '%s' without a match expression", metric.Name, label)
-					}
-					if re.NumSubexp() < submatch {
-						return nil, fmt.Errorf("configuration for metric '%s' invalid: Match expression for key '%s' does not contain %d subexpressions", metric.Name, label, submatch)
-					}
-					config.Metrics[i].labelLookupMap[key] = func(_ *v1.Event, matches map[string][]string) (string, error) { //nolint:unparam
-						return matches[label][submatch], nil
-					}
-				} else {
-					config.Metrics[i].labelLookupMap[key] = func(event *v1.Event, _ map[string][]string) (string, error) {
-						return GetValueFromStruct(event, labelSpec)
-					}
+		metric.labelLookupMap = make(map[string]LookupFunc, len(metric.Labels))
+		metric.labelTransformMap = make(map[string][]TransformFunc, len(metric.Labels))
+		for key, label := range metric.Labels {
+			lookup, err := newLabelSourcesLookup(metric, key, label)
+			if err != nil {
+				return nil, err
+			}
+			metric.labelLookupMap[key] = lookup
+
+			for _, t := range label.Transform {
+				transform, err := newTransform(t)
+				if err != nil {
+					return nil, fmt.Errorf("configuration for metric '%s' invalid: transformation of label '%s' invalid: %w", metric.Name, key, err)
 				}
+				metric.labelTransformMap[key] = append(metric.labelTransformMap[key], transform)
+			}
+		}
+
+		switch {
+		case metric.Condition != nil || metric.Latency != nil:
+			if metric.itemsLookup != nil {
+				return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't use a condition or latency together with an expansion", metric.Name)
+			}
+		case metric.Distinct != nil:
+			if err := compileDistinct(metric); err != nil {
+				return nil, err
+			}
+		default:
+			if err := compileMetricType(metric); err != nil {
+				return nil, err
 			}
 		}
 	}
 
 	return &config, nil
 }
+
+// countSet returns how many of the given conditions are true.
+func countSet(conditions ...bool) int {
+	count := 0
+	for _, c := range conditions {
+		if c {
+			count++
+		}
+	}
+	return count
+}
+
+// maxAge returns the age after which events are discarded for the metric.
+func (metric *MetricConfig) maxAge() time.Duration {
+	if metric.MaxAge != 0 {
+		return metric.MaxAge
+	}
+	return discardInterval
+}
+
+// newLabelSourcesLookup creates the lookup for a label with one or more
+// sources. Sources are tried in order until one yields a non-empty value.
+func newLabelSourcesLookup(metric *MetricConfig, key string, label LabelConfig) (LookupFunc, error) {
+	sources := label.Sources
+	if label.Source != "" {
+		if len(sources) > 0 {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Label '%s' has both source and sources", metric.Name, key)
+		}
+		sources = []string{label.Source}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: No source for label '%s'", metric.Name, key)
+	}
+
+	lookups := make([]LookupFunc, len(sources))
+	for i, source := range sources {
+		var err error
+		lookups[i], err = newLabelLookup(metric, source)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(lookups) == 1 {
+		return lookups[0], nil
+	}
+
+	return func(ctx *LookupContext) (string, error) {
+		var errs []error
+		found := false
+		for _, lookup := range lookups {
+			value, err := lookup(ctx)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if value != "" {
+				return value, nil
+			}
+			found = true
+		}
+		if found {
+			return "", nil
+		}
+		return "", errors.Join(errs...)
+	}, nil
+}
+
+// newLabelLookup creates the lookup for a label value. Besides all keys which
+// can be used in event matchers, labels can refer to submatches of the
+// matchers and to objects which have to be looked up through the API.
+func newLabelLookup(metric *MetricConfig, labelSpec string) (LookupFunc, error) {
+	switch {
+	case isLiteral(labelSpec):
+		value, err := strconv.Unquote(labelSpec)
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: literal %s invalid: %w", metric.Name, labelSpec, err)
+		}
+		return func(_ *LookupContext) (string, error) { //nolint:unparam
+			return value, nil
+		}, nil
+	case isTemplate(labelSpec):
+		return newTemplateLookup(metric, labelSpec)
+	case isTableLookup(labelSpec):
+		return newTableLookup(metric, labelSpec)
+	case strings.HasPrefix(labelSpec, PodVirtualTypePrefix):
+		return func(ctx *LookupContext) (string, error) {
+			pod, err := ctx.Object()
+			if err != nil {
+				return "", err
+			}
+			return GetValueFromStruct(pod, strings.TrimPrefix(labelSpec, PodVirtualTypePrefix))
+		}, nil
+	case strings.HasPrefix(labelSpec, VolumeVirtualTypePrefix):
+		return func(ctx *LookupContext) (string, error) {
+			volume, err := ctx.volumeInfo()
+			if err != nil {
+				return "", err
+			}
+			return GetValueFromStruct(volume, strings.TrimPrefix(labelSpec, VolumeVirtualTypePrefix))
+		}, nil
+	case strings.HasPrefix(labelSpec, ImageVirtualTypePrefix):
+		return func(ctx *LookupContext) (string, error) {
+			image, err := getImageReferenceForEvent(ctx)
+			if err != nil {
+				return "", err
+			}
+			return GetValueFromStruct(image, strings.TrimPrefix(labelSpec, ImageVirtualTypePrefix))
+		}, nil
+	case strings.HasPrefix(labelSpec, ProviderVirtualTypePrefix):
+		return func(ctx *LookupContext) (string, error) {
+			provider, err := ctx.providerInfo()
+			if err != nil {
+				return "", err
+			}
+			return GetValueFromStruct(provider, strings.TrimPrefix(labelSpec, ProviderVirtualTypePrefix))
+		}, nil
+	case strings.HasPrefix(labelSpec, ContainerVirtualTypePrefix):
+		return func(ctx *LookupContext) (string, error) {
+			container, err := getContainerInfoForEvent(ctx)
+			if err != nil {
+				return "", err
+			}
+			return GetValueFromStruct(container, strings.TrimPrefix(labelSpec, ContainerVirtualTypePrefix))
+		}, nil
+	case labelSpec == ItemVirtualType || strings.HasPrefix(labelSpec, ItemVirtualType+".") || strings.HasPrefix(labelSpec, ItemVirtualType+"["):
+		return newItemLookup(metric, labelSpec)
+	case isSchedulingReasonField(labelSpec):
+		// shorthand for expanding the metric over Scheduling.Reasons
+		if metric.Expand != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't use '%s' together with an expansion", metric.Name, labelSpec)
+		}
+		metric.itemsLookup = limitItems(metric, defaultExpandLimit, schedulingReasonsLookup)
+		return func(ctx *LookupContext) (string, error) {
+			return GetValueFromStruct(ctx.Item, strings.TrimPrefix(labelSpec, SchedulingVirtualTypePrefix))
+		}, nil
+	}
+
+	// an index is a submatch if there is a matcher for the key, or else selects an element of the event
+	if matches := labelSubMatchRE.FindStringSubmatch(labelSpec); matches != nil && !isEventElement(metric, matches[1]) {
+		label := matches[1]
+		re, found := metric.matchers.regexMap[label]
+		if !found {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't use a submatch for key '%s' without a match expression", metric.Name, label)
+		}
+		submatch, err := resolveSubmatch(re, matches[2])
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Match expression for key '%s' %w", metric.Name, label, err)
+		}
+		return func(ctx *LookupContext) (string, error) { //nolint:unparam
+			return ctx.Matches[label][submatch], nil
+		}, nil
+	}
+
+	lookup, err := newKeyLookup(metric, labelSpec)
+	if err != nil {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: %w", metric.Name, err)
+	}
+	return lookup, nil
+}
+
+// isEventElement reports whether an index after key selects an element of a
+// list or map of the event rather than a submatch of the matcher for key.
+func isEventElement(metric *MetricConfig, key string) bool {
+	if _, found := metric.matchers.regexMap[key]; found {
+		return false
+	}
+	return isIndexablePath(reflect.TypeOf(v1.Event{}), key)
+}
+
+// isLiteral reports whether a label source is a literal value in double quotes.
+func isLiteral(labelSpec string) bool {
+	return len(labelSpec) >= 2 && strings.HasPrefix(labelSpec, `"`) && strings.HasSuffix(labelSpec, `"`)
+}
+
+// resolveSubmatch returns the index of the submatch referred to by group,
+// which is either a number or the name of a capture group.
+func resolveSubmatch(re *regexp.Regexp, group string) (int, error) {
+	if index, err := strconv.Atoi(group); err == nil {
+		if re.NumSubexp() < index {
+			return 0, fmt.Errorf("does not contain %d subexpressions", index)
+		}
+		return index, nil
+	}
+	index := re.SubexpIndex(group)
+	if index < 0 {
+		return 0, fmt.Errorf("does not contain a group named '%s'", group)
+	}
+	return index, nil
+}
+
+// newKeyLookup creates the lookup for a key of an event matcher, which is
+// either a field of the event or a value parsed from it. The metric is nil
+// for matchers outside of metrics.
+func newKeyLookup(metric *MetricConfig, key string) (LookupFunc, error) {
+	switch {
+	case key == SeverityKey || key == CategoryKey:
+		return newClassificationLookup(key), nil
+	case isSchedulingReasonField(key):
+		return nil, fmt.Errorf("Can't use per-reason field '%s' as a single value, use '%sReasons' or '%sTopReason' instead", key, SchedulingVirtualTypePrefix, SchedulingVirtualTypePrefix)
+	case strings.HasPrefix(key, SchedulingVirtualTypePrefix):
+		return func(ctx *LookupContext) (string, error) {
+			info, err := ParseSchedulingMessage(ctx.Event.Message)
+			if err != nil {
+				return "", err
+			}
+			return GetValueFromStruct(info, strings.TrimPrefix(key, SchedulingVirtualTypePrefix))
+		}, nil
+	case strings.HasPrefix(key, MessageFieldsPrefix):
+		return newMessageFieldLookup(metric, key)
+	case key == MessageFingerprintKey:
+		return func(ctx *LookupContext) (string, error) { //nolint:unparam
+			return FingerprintMessage(ctx.Event.Message), nil
+		}, nil
+	case key == MessageFingerprintHashKey:
+		return func(ctx *LookupContext) (string, error) { //nolint:unparam
+			return FingerprintMessageHash(ctx.Event.Message), nil
+		}, nil
+	}
+
+	return func(ctx *LookupContext) (string, error) {
+		return GetValueFromStruct(ctx.Event, key)
+	}, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	ContainerVirtualTypePrefix = "Container."
+)
+
+var (
+	// matches e.g. `spec.containers{sidecar}`
+	containerFieldPathRE = regexp.MustCompile(`^spec\.(containers|initContainers|ephemeralContainers)\{(.+)\}$`)
+)
+
+// ContainerInfo describes the container an event refers to through
+// InvolvedObject.FieldPath. Its fields are exposed as `Container.<Field>` labels.
+type ContainerInfo struct {
+	Name                    string
+	Image                   string
+	ImageTag                string
+	RestartCount            string
+	LastTerminationReason   string
+	LastTerminationExitCode string
+}
+
+func getContainerInfoForEvent(ctx *LookupContext) (*ContainerInfo, error) {
+	fieldPath := ctx.Event.InvolvedObject.FieldPath
+	match := containerFieldPathRE.FindStringSubmatch(fieldPath)
+	if match == nil {
+		return nil, fmt.Errorf("field path '%s' does not refer to a container", fieldPath)
+	}
+	kind, name := match[1], match[2]
+
+	pod, err := ctx.Object()
+	if err != nil {
+		return nil, err
+	}
+
+	var image string
+	var statuses []v1.ContainerStatus
+	switch kind {
+	case "containers":
+		image, err = findContainerImage(pod.Spec.Containers, name)
+		statuses = pod.Status.ContainerStatuses
+	case "initContainers":
+		image, err = findContainerImage(pod.Spec.InitContainers, name)
+		statuses = pod.Status.InitContainerStatuses
+	case "ephemeralContainers":
+		containers := make([]v1.Container, 0, len(pod.Spec.EphemeralContainers))
+		for _, c := range pod.Spec.EphemeralContainers {
+			containers = append(containers, v1.Container(c.EphemeralContainerCommon))
+		}
+		image, err = findContainerImage(containers, name)
+		statuses = pod.Status.EphemeralContainerStatuses
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ContainerInfo{Name: name, Image: image, ImageTag: ParseImageReference(image).Tag}
+	// the status is missing until the container has been created, which is not an error
+	for _, status := range statuses {
+		if status.Name != name {
+			continue
+		}
+		info.RestartCount = strconv.Itoa(int(status.RestartCount))
+		terminated := status.LastTerminationState.Terminated
+		if terminated == nil {
+			terminated = status.State.Terminated
+		}
+		if terminated != nil {
+			info.LastTerminationReason = terminated.Reason
+			info.LastTerminationExitCode = strconv.Itoa(int(terminated.ExitCode))
+		}
+	}
+
+	return info, nil
+}
+
+func findContainerImage(containers []v1.Container, name string) (string, error) {
+	for _, c := range containers {
+		if c.Name == name {
+			return c.Image, nil
+		}
+	}
+	return "", fmt.Errorf("container '%s' not found in pod spec", name)
+}
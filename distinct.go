@@ -0,0 +1,243 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// number of items per series which are tracked exactly by default
+	defaultDistinctMaxExact = 1000
+	// number of sketches a window is divided into for approximate counting
+	distinctBuckets = 12
+)
+
+// DistinctConfig turns a metric into a gauge of the number of distinct
+// objects, or values of Key, which matched within the metric's window.
+type DistinctConfig struct {
+	Key string `yaml:"key"`
+	// number of items per series above which they are counted approximately
+	MaxExact  int `yaml:"max_exact"`
+	keyLookup LookupFunc
+}
+
+// compileDistinct validates the distinct options of a metric and creates the
+// lookup for its key.
+func compileDistinct(metric *MetricConfig) error {
+	distinct := metric.Distinct
+	if metric.Type != "" || metric.Value != "" || metric.Accounting != "" || metric.Buckets != nil || metric.Objectives != nil || metric.NativeHistogramBucketFactor != 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: Can't use type, value, accounting or histogram options together with distinct", metric.Name)
+	}
+	if metric.Window <= 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: distinct needs a window", metric.Name)
+	}
+	switch {
+	case distinct.MaxExact < 0:
+		return fmt.Errorf("configuration for metric '%s' invalid: max_exact must not be negative", metric.Name)
+	case distinct.MaxExact == 0:
+		distinct.MaxExact = defaultDistinctMaxExact
+	}
+
+	if distinct.Key == "" {
+		distinct.keyLookup = func(ctx *LookupContext) (string, error) { //nolint:unparam
+			return involvedObjectKey(ctx.Event), nil
+		}
+		return nil
+	}
+	var err error
+	distinct.keyLookup, err = newLabelLookup(metric, distinct.Key)
+	return err
+}
+
+// distinctVec is a collector which exports the number of distinct items per
+// label set within a sliding window as gauges. Items are tracked exactly with
+// the time they were seen last, until a series exceeds maxExact items. Then
+// it switches to a ring of HyperLogLog sketches, one per time bucket, which
+// are merged for the estimate. Series without items in the window are
+// removed.
+type distinctVec struct {
+	desc        *prometheus.Desc
+	labels      []string
+	window      time.Duration
+	bucketWidth time.Duration
+	maxExact    int
+	now         func() time.Time
+
+	mu     sync.Mutex
+	series map[string]*distinctSeries
+}
+
+type distinctSeries struct {
+	labelValues []string
+	// time each item was seen last, nil after switching to sketches
+	exact    map[string]time.Time
+	sketches [distinctBuckets]*hyperLogLog
+	// number of the newest sketch, counted in bucket widths since the epoch
+	newest int64
+}
+
+func newDistinctVec(name, help string, labels []string, window time.Duration, maxExact int) *distinctVec {
+	bucketWidth := window / distinctBuckets
+	if bucketWidth <= 0 {
+		bucketWidth = 1
+	}
+	return &distinctVec{
+		desc:        prometheus.NewDesc(name, help, labels, nil),
+		labels:      labels,
+		window:      window,
+		bucketWidth: bucketWidth,
+		maxExact:    maxExact,
+		now:         time.Now,
+		series:      make(map[string]*distinctSeries),
+	}
+}
+
+// Update adds the item of a match, which is in its Object field.
+func (v *distinctVec) Update(match FilterMatch) error {
+	return v.Add(match.Labels, match.Object)
+}
+
+// Add adds an item to the series with the given labels.
+func (v *distinctVec) Add(labels map[string]string, item string) error {
+	if len(labels) != len(v.labels) {
+		return fmt.Errorf("expected labels %v, got %v", v.labels, labels)
+	}
+	labelValues := make([]string, len(v.labels))
+	for i, name := range v.labels {
+		value, found := labels[name]
+		if !found {
+			return fmt.Errorf("label '%s' missing", name)
+		}
+		labelValues[i] = value
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	series, found := v.series[key]
+	if !found {
+		series = &distinctSeries{labelValues: labelValues, exact: make(map[string]time.Time), newest: v.bucket(now)}
+		v.series[key] = series
+	}
+
+	if series.exact != nil {
+		series.exact[item] = now
+		if len(series.exact) > v.maxExact {
+			v.expireExact(series, now)
+		}
+		if len(series.exact) > v.maxExact {
+			v.switchToSketches(series, now)
+		}
+		return nil
+	}
+
+	bucket := v.bucket(now)
+	series.advance(bucket)
+	sketch := series.sketches[bucket%distinctBuckets]
+	if sketch == nil {
+		sketch = &hyperLogLog{}
+		series.sketches[bucket%distinctBuckets] = sketch
+	}
+	sketch.Add(item)
+	return nil
+}
+
+func (v *distinctVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+func (v *distinctVec) Collect(ch chan<- prometheus.Metric) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	for key, series := range v.series {
+		var count float64
+		if series.exact != nil {
+			v.expireExact(series, now)
+			count = float64(len(series.exact))
+		} else {
+			series.advance(v.bucket(now))
+			var merged hyperLogLog
+			for _, sketch := range series.sketches {
+				if sketch != nil {
+					merged.Merge(sketch)
+					count = 1
+				}
+			}
+			if count > 0 {
+				count = merged.Estimate()
+			}
+		}
+		if count == 0 {
+			delete(v.series, key)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(v.desc, prometheus.GaugeValue, count, series.labelValues...)
+	}
+}
+
+func (v *distinctVec) bucket(t time.Time) int64 {
+	return t.UnixNano() / int64(v.bucketWidth)
+}
+
+// expireExact removes the items which have not been seen within the window.
+func (v *distinctVec) expireExact(series *distinctSeries, now time.Time) {
+	for item, seen := range series.exact {
+		if now.Sub(seen) > v.window {
+			delete(series.exact, item)
+		}
+	}
+}
+
+// switchToSketches moves the exactly tracked items of a series into the
+// sketches of the buckets they were seen last in.
+func (v *distinctVec) switchToSketches(series *distinctSeries, now time.Time) {
+	bucket := v.bucket(now)
+	series.newest = bucket
+	for item, seen := range series.exact {
+		seenBucket := v.bucket(seen)
+		if bucket-seenBucket >= distinctBuckets {
+			continue
+		}
+		sketch := series.sketches[seenBucket%distinctBuckets]
+		if sketch == nil {
+			sketch = &hyperLogLog{}
+			series.sketches[seenBucket%distinctBuckets] = sketch
+		}
+		sketch.Add(item)
+	}
+	series.exact = nil
+}
+
+// advance drops the sketches which left the window since the newest one.
+func (s *distinctSeries) advance(bucket int64) {
+	if bucket-s.newest >= distinctBuckets {
+		s.sketches = [distinctBuckets]*hyperLogLog{}
+	} else {
+		for b := s.newest + 1; b <= bucket; b++ {
+			s.sketches[b%distinctBuckets] = nil
+		}
+	}
+	if bucket > s.newest {
+		s.newest = bucket
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+// distinctValues collects the current counts of a distinctVec by reason label.
+func distinctValues(t *testing.T, vec *distinctVec) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 10)
+	vec.Collect(ch)
+	close(ch)
+	values := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+		values[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestHyperLogLog(t *testing.T) {
+	var a, b hyperLogLog
+	require.InDelta(t, 0, a.Estimate(), 0)
+	for i := 0; i < 50000; i++ {
+		a.Add("pod-" + strconv.Itoa(i))
+		// duplicates don't count
+		a.Add("pod-" + strconv.Itoa(i))
+	}
+	require.InEpsilon(t, 50000, a.Estimate(), 0.05)
+
+	for i := 25000; i < 75000; i++ {
+		b.Add("pod-" + strconv.Itoa(i))
+	}
+	a.Merge(&b)
+	require.InEpsilon(t, 75000, a.Estimate(), 0.05)
+}
+
+func TestDistinctVec(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	vec := newDistinctVec("pods_in_backoff", "help", []string{"reason"}, time.Hour, 100)
+	vec.now = func() time.Time { return now }
+
+	require.NoError(t, vec.Add(map[string]string{"reason": "BackOff"}, "pod-a"))
+	require.NoError(t, vec.Add(map[string]string{"reason": "BackOff"}, "pod-a"))
+	require.NoError(t, vec.Add(map[string]string{"reason": "BackOff"}, "pod-b"))
+	require.NoError(t, vec.Add(map[string]string{"reason": "Failed"}, "pod-a"))
+	require.Equal(t, map[string]float64{"BackOff": 2, "Failed": 1}, distinctValues(t, vec))
+
+	now = now.Add(50 * time.Minute)
+	require.NoError(t, vec.Add(map[string]string{"reason": "BackOff"}, "pod-b"))
+	now = now.Add(20 * time.Minute)
+	require.Equal(t, map[string]float64{"BackOff": 1}, distinctValues(t, vec))
+
+	// above max_exact, items are counted approximately
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, vec.Add(map[string]string{"reason": "BackOff"}, "pod-"+strconv.Itoa(i)))
+	}
+	require.Nil(t, vec.series["BackOff"].exact)
+	require.InEpsilon(t, 1001, distinctValues(t, vec)["BackOff"], 0.05)
+
+	now = now.Add(2 * time.Hour)
+	require.Empty(t, distinctValues(t, vec))
+}
+
+func TestDistinctKey(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: pods_in_backoff
+  window: 1h
+  distinct: {}
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+- name: nodes_with_pull_errors
+  window: 1h
+  distinct:
+    key: Source.Host
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+`))
+	require.NoError(t, err)
+
+	event := v1.Event{Reason: "BackOff", InvolvedObject: v1.ObjectReference{UID: "1234"}, Source: v1.EventSource{Host: "node-1"}}
+	require.Equal(t, []FilterMatch{
+		{Name: "pods_in_backoff", Labels: map[string]string{}, Value: 1, Object: "1234"},
+		{Name: "nodes_with_pull_errors", Labels: map[string]string{}, Value: 1, Object: "node-1"},
+	}, LogEvent(&event, &EventRouter{Config: config}))
+
+	_, err = NewConfig(bytes.NewBufferString("metrics:\n- name: d\n  distinct: {}\n"))
+	require.EqualError(t, err, "configuration for metric 'd' invalid: distinct needs a window")
+}
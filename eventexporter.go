@@ -29,40 +29,59 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
+const (
+	// count no events of the initial list, only those delivered by the watch
+	StartupPolicySkip = "skip"
+	// count events of the initial list within the discard interval
+	StartupPolicyWindow = "window"
+	// count all events of the initial list
+	StartupPolicyAll = "all"
+)
+
 var (
-	kubernetesEventCounterVec map[string]*prometheus.CounterVec
+	kubernetesEventMetricVec map[string]prometheus.Collector
 )
 
+// objectMetricVec is a collector which correlates matches about the same
+// object, like conditions and latencies.
+type objectMetricVec interface {
+	prometheus.Collector
+	Update(match FilterMatch) error
+}
+
 type EventRouter struct {
 	kubeClient     kubernetes.Interface
 	eLister        corelisters.EventLister
 	eListerSynched cache.InformerSynced
 	Config         *Config
+	// processed event versions by UID, nil if deduplication is disabled
+	processed *lruCache
 }
 
 func NewEventRouter(kubeClient kubernetes.Interface, eventsInformer coreinformers.EventInformer, config *Config) (*EventRouter, error) {
-	kubernetesEventCounterVec = make(map[string]*prometheus.CounterVec)
+	kubernetesEventMetricVec = make(map[string]prometheus.Collector)
 
-	for _, metric := range config.Metrics {
+	for i := range config.Metrics {
+		metric := &config.Metrics[i]
 		var labels []string
 
 		for key := range metric.Labels {
 			labels = append(labels, key)
 		}
 
-		kubernetesEventCounterVec[metric.Name] = prometheus.NewCounterVec(prometheus.CounterOpts{
-			Name: metric.Name,
-			Help: "Kubernetes Eventexporter Metric " + metric.Name,
-		}, labels)
+		kubernetesEventMetricVec[metric.Name] = newMetricCollector(metric, labels)
 
-		prometheus.MustRegister(kubernetesEventCounterVec[metric.Name])
+		prometheus.MustRegister(kubernetesEventMetricVec[metric.Name])
 	}
 
 	router := &EventRouter{
 		kubeClient: kubeClient,
 		Config:     config,
 	}
-	_, err := eventsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	if dedupSize > 0 {
+		router.processed = newLRUCache(dedupSize)
+	}
+	_, err := eventsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
 		AddFunc:    router.addEvent,
 		UpdateFunc: router.updateEvent,
 		DeleteFunc: router.deleteEvent,
@@ -89,21 +108,33 @@ func (er *EventRouter) Run(stopCh <-chan struct{}) {
 	<-stopCh
 }
 
-func (er *EventRouter) addEvent(obj interface{}) {
+func (er *EventRouter) addEvent(obj interface{}, isInInitialList bool) {
 	e, ok := obj.(*v1.Event)
 	if !ok {
 		glog.Warning("got non event from informer")
 		return
 	}
 
-	if discardEvent(e) {
-		glog.V(5).Infof("Discarding event: %v", e)
+	previous, duplicate := er.markProcessed(e)
+	if duplicate {
+		glog.V(5).Infof("Skipping already processed event: %v", e)
 		return
 	}
 
-	filterMatches := LogEvent(e, er)
+	if isInInitialList && startupPolicy == StartupPolicySkip {
+		glog.V(5).Infof("Skipping event of initial list: %v", e)
+		return
+	}
+	checkAge := !isInInitialList || startupPolicy != StartupPolicyAll
+
+	occurrences := eventOccurrences(e)
+	if previous != nil {
+		// an earlier version has been processed, e.g. before a restart
+		occurrences = occurrencesAfter(previous.Occurrences, e)
+	}
+	filterMatches := logEvent(e, occurrences, checkAge, er)
 	for _, filterMatch := range filterMatches {
-		prometheusEvent(filterMatch.Name, filterMatch.Labels)
+		prometheusEvent(filterMatch)
 	}
 }
 
@@ -113,30 +144,35 @@ func (er *EventRouter) updateEvent(objOld, objNew interface{}) {
 		glog.Warning("got non event from informer")
 		return
 	}
+	eOld, _ := objOld.(*v1.Event)
 
-	if discardEvent(eNew) {
-		glog.V(5).Infof("Discarding event: %v", eNew)
+	previous, duplicate := er.markProcessed(eNew)
+	if duplicate {
+		glog.V(5).Infof("Skipping already processed event: %v", eNew)
 		return
 	}
 
-	filterMatches := LogEvent(eNew, er)
+	occurrences := occurrencesSince(eOld, eNew)
+	if previous != nil {
+		occurrences = occurrencesAfter(previous.Occurrences, eNew)
+	}
+	filterMatches := logEvent(eNew, occurrences, true, er)
 	for _, filterMatch := range filterMatches {
-		prometheusEvent(filterMatch.Name, filterMatch.Labels)
+		prometheusEvent(filterMatch)
 	}
 }
 
-func prometheusEvent(filter string, labels map[string]string) {
-	var counter prometheus.Counter
-	var err error
-
-	glog.V(5).Infof("Sending labels: %v", labels)
-
-	counter, err = kubernetesEventCounterVec[filter].GetMetricWith(labels)
+func prometheusEvent(filterMatch FilterMatch) {
+	glog.V(5).Infof("Sending labels: %v value: %g", filterMatch.Labels, filterMatch.Value)
 
+	var err error
+	if vec, ok := kubernetesEventMetricVec[filterMatch.Name].(objectMetricVec); ok {
+		err = vec.Update(filterMatch)
+	} else {
+		err = observeMetric(kubernetesEventMetricVec[filterMatch.Name], filterMatch.Labels, filterMatch.Value)
+	}
 	if err != nil {
 		glog.Warning(err)
-	} else {
-		counter.Add(1)
 	}
 }
 
@@ -147,8 +183,47 @@ func (er *EventRouter) deleteEvent(obj interface{}) {
 		return
 	}
 	glog.V(5).Infof("Event Deleted from the system:\n%v", e)
+	if er.processed != nil {
+		er.processed.Remove(string(e.UID))
+	}
 }
 
-func discardEvent(e *v1.Event) bool {
-	return time.Since(e.LastTimestamp.Time) > discardInterval
+// markProcessed records this version of the event as processed. It returns
+// the version processed before, nil if there is none, and whether this
+// version is a duplicate delivery of it.
+func (er *EventRouter) markProcessed(e *v1.Event) (*eventVersion, bool) {
+	if er.processed == nil {
+		return nil, false
+	}
+	previous, found := er.processed.Get(string(e.UID))
+	if found && previous.ResourceVersion == e.ResourceVersion {
+		duplicateEventsCounter.Inc()
+		return &previous, true
+	}
+	er.processed.Add(string(e.UID), eventVersion{ResourceVersion: e.ResourceVersion, Occurrences: eventOccurrences(e)})
+	if !found {
+		return nil, false
+	}
+	return &previous, false
+}
+
+// discardEvent reports whether an event is older than maxAge. A maxAge of 0
+// disables discarding.
+func discardEvent(e *v1.Event, maxAge time.Duration) bool {
+	return maxAge > 0 && time.Since(EventTimestamp(e)) > maxAge
+}
+
+// EventTimestamp returns when an event occurred last. Events created through
+// the events.k8s.io API often have no LastTimestamp but the time of the last
+// repetition in Series.LastObservedTime, or only an EventTime.
+func EventTimestamp(e *v1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
 }
@@ -0,0 +1,89 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStartupPolicy(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: events
+  labels:
+    reason: Reason
+`))
+	require.NoError(t, err)
+	defer func(policy string) { startupPolicy = policy }(startupPolicy)
+
+	recent := &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1"}, Reason: "Recent", LastTimestamp: metav1.NewTime(time.Now())}
+	old := &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "2"}, Reason: "Old", LastTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))}
+	for policy, expected := range map[string][]float64{
+		StartupPolicySkip:   {0, 0},
+		StartupPolicyWindow: {1, 0},
+		StartupPolicyAll:    {1, 1},
+	} {
+		startupPolicy = policy
+		vec := newMetricCollector(&config.Metrics[0], []string{"reason"}).(*prometheus.CounterVec)
+		kubernetesEventMetricVec = map[string]prometheus.Collector{"events": vec}
+		router := &EventRouter{Config: config}
+
+		router.addEvent(recent, true)
+		router.addEvent(old, true)
+		require.Equal(t, expected, []float64{counterValue(t, vec, "Recent"), counterValue(t, vec, "Old")}, policy)
+
+		// events delivered by the watch don't depend on the policy
+		router.addEvent(recent, false)
+		router.addEvent(old, false)
+		require.Equal(t, []float64{expected[0] + 1, expected[1]}, []float64{counterValue(t, vec, "Recent"), counterValue(t, vec, "Old")}, policy)
+	}
+}
+
+func TestEventTimestamp(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	event := &v1.Event{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	require.Equal(t, created, EventTimestamp(event))
+	event.EventTime = metav1.NewMicroTime(created.Add(time.Second))
+	require.Equal(t, created.Add(time.Second), EventTimestamp(event))
+	event.Series = &v1.EventSeries{Count: 2, LastObservedTime: metav1.NewMicroTime(created.Add(time.Minute))}
+	require.Equal(t, created.Add(time.Minute), EventTimestamp(event))
+	event.LastTimestamp = metav1.NewTime(created.Add(time.Hour))
+	require.Equal(t, created.Add(time.Hour), EventTimestamp(event))
+}
+
+func TestMaxAge(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: default
+- name: long
+  max_age: 2h
+`))
+	require.NoError(t, err)
+	router := &EventRouter{Config: config}
+
+	event := &v1.Event{EventTime: metav1.NewMicroTime(time.Now().Add(-time.Hour))}
+	require.Equal(t, []FilterMatch{
+		{Name: "long", Labels: map[string]string{}, Value: 1},
+	}, logEvent(event, 1, true, router))
+	require.Len(t, logEvent(event, 1, false, router), 2)
+
+	event.EventTime = metav1.NewMicroTime(time.Now())
+	require.Len(t, logEvent(event, 1, true, router), 2)
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+const (
+	ItemVirtualType    = "Item"
+	defaultExpandLimit = 10
+)
+
+var (
+	itemSubMatchRE = regexp.MustCompile(`^Item\[(\w+)\]$`)
+)
+
+// ExpandConfig makes a metric yield one series per element of a list instead
+// of a single series per event. The list is either built from all matches of
+// Expr in the value of Source, or Source refers to a list itself.
+type ExpandConfig struct {
+	Source string `yaml:"source"`
+	Expr   string `yaml:"expr"`
+	Limit  int    `yaml:"limit"`
+}
+
+func newItemsLookup(metric *MetricConfig) (ItemsFunc, error) {
+	expand := metric.Expand
+	if expand.Source == "" {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: Expansion without source", metric.Name)
+	}
+	if expand.Limit < 0 {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: Expansion limit must not be negative", metric.Name)
+	}
+	limit := expand.Limit
+	if limit == 0 {
+		limit = defaultExpandLimit
+	}
+
+	if expand.Expr != "" {
+		re, err := regexp.Compile(expand.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: expansion expression invalid: %w", metric.Name, err)
+		}
+		metric.expandRegex = re
+		source, err := newKeyLookup(metric, expand.Source)
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: %w", metric.Name, err)
+		}
+		return limitItems(metric, limit, func(ctx *LookupContext) ([]interface{}, error) {
+			value, err := source(ctx)
+			if err != nil {
+				return nil, err
+			}
+			var items []interface{}
+			for _, match := range re.FindAllStringSubmatch(value, -1) {
+				items = append(items, match)
+			}
+			return items, nil
+		}), nil
+	}
+
+	switch {
+	case expand.Source == SchedulingVirtualTypePrefix+"Reasons":
+		return limitItems(metric, limit, schedulingReasonsLookup), nil
+	case strings.HasPrefix(expand.Source, PodVirtualTypePrefix):
+		return limitItems(metric, limit, func(ctx *LookupContext) ([]interface{}, error) {
+			pod, err := ctx.Object()
+			if err != nil {
+				return nil, err
+			}
+			return listItems(pod, strings.TrimPrefix(expand.Source, PodVirtualTypePrefix))
+		}), nil
+	default:
+		return limitItems(metric, limit, func(ctx *LookupContext) ([]interface{}, error) {
+			return listItems(ctx.Event, expand.Source)
+		}), nil
+	}
+}
+
+func limitItems(metric *MetricConfig, limit int, lookup ItemsFunc) ItemsFunc {
+	return func(ctx *LookupContext) ([]interface{}, error) {
+		items, err := lookup(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > limit {
+			glog.Warningf("Expansion of metric '%s' limited to %d of %d elements", metric.Name, limit, len(items))
+			items = items[:limit]
+		}
+		return items, nil
+	}
+}
+
+// listItems returns the elements of the list at the dotted path key.
+func listItems(object interface{}, key string) ([]interface{}, error) {
+	field, err := GetFieldFromStruct(object, key)
+	if err != nil {
+		return nil, err
+	}
+	value := reflect.ValueOf(field)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, fmt.Errorf("value of %s is not a list", key)
+	}
+	items := make([]interface{}, value.Len())
+	for i := range items {
+		items[i] = value.Index(i).Interface()
+	}
+	return items, nil
+}
+
+// newItemLookup creates the lookup for a label referring to the element the
+// metric is expanded over: `Item` for the element itself or the whole match,
+// `Item[n]` or `Item[name]` for a submatch and `Item.<Field>` for a field of the element.
+func newItemLookup(metric *MetricConfig, labelSpec string) (LookupFunc, error) {
+	if metric.Expand == nil {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't use '%s' without an expansion", metric.Name, labelSpec)
+	}
+
+	if labelSpec == ItemVirtualType {
+		return func(ctx *LookupContext) (string, error) {
+			switch item := ctx.Item.(type) {
+			case string:
+				return item, nil
+			case []string:
+				return item[0], nil
+			}
+			return "", errors.New("item is not a string")
+		}, nil
+	}
+
+	if matches := itemSubMatchRE.FindStringSubmatch(labelSpec); matches != nil {
+		if metric.expandRegex == nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't use a submatch of '%s' without an expansion expression", metric.Name, ItemVirtualType)
+		}
+		submatch, err := resolveSubmatch(metric.expandRegex, matches[1])
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: Expansion expression %w", metric.Name, err)
+		}
+		return func(ctx *LookupContext) (string, error) { //nolint:unparam
+			return ctx.Item.([]string)[submatch], nil
+		}, nil
+	}
+
+	if field, ok := strings.CutPrefix(labelSpec, ItemVirtualType+"."); ok {
+		return func(ctx *LookupContext) (string, error) {
+			return GetValueFromStruct(ctx.Item, field)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't parse label '%s'", metric.Name, labelSpec)
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExpandRegex(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: unmounted
+  event_matcher:
+  - key: Reason
+    expr: FailedMount
+  expand:
+    source: Message
+    expr: volume "([^"]+)"
+    limit: 2
+  labels:
+    volume: Item[1]
+    reason: Reason
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	testEvent := v1.Event{
+		Reason:  "FailedMount",
+		Message: `volume "data" failed, volume "cache" failed, volume "logs" failed`,
+	}
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+
+	require.Equal(t, []FilterMatch{
+		{Name: "unmounted", Labels: map[string]string{"volume": "data", "reason": "FailedMount"}, Value: 1},
+		{Name: "unmounted", Labels: map[string]string{"volume": "cache", "reason": "FailedMount"}, Value: 1},
+	}, matches)
+}
+
+func TestExpandObjectList(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: images
+  event_matcher:
+  - key: Reason
+    expr: Killing
+  expand:
+    source: Object.Spec.Containers
+  labels:
+    image: Item.Image
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "app", Image: "app:1.0"},
+			{Name: "sidecar", Image: "proxy:2.0"},
+		}},
+	}
+	event := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+		Reason:         "Killing",
+	}
+
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fake.NewSimpleClientset(pod)})
+	require.Equal(t, []FilterMatch{
+		{Name: "images", Labels: map[string]string{"image": "app:1.0"}, Value: 1},
+		{Name: "images", Labels: map[string]string{"image": "proxy:2.0"}, Value: 1},
+	}, matches)
+}
+
+func TestExpandSchedulingReasons(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: unschedulable
+  event_matcher:
+  - key: Reason
+    expr: FailedScheduling
+  expand:
+    source: Scheduling.Reasons
+  labels:
+    reason: Item.Reason
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	testEvent := v1.Event{
+		Reason:  "FailedScheduling",
+		Message: "0/4 nodes are available: 1 Insufficient memory, 3 Insufficient cpu.",
+	}
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+
+	require.Equal(t, []FilterMatch{
+		{Name: "unschedulable", Labels: map[string]string{"reason": "Insufficient memory"}, Value: 1},
+		{Name: "unschedulable", Labels: map[string]string{"reason": "Insufficient cpu"}, Value: 1},
+	}, matches)
+}
+
+func TestConfigErrorItemWithoutExpansion(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: item
+  event_matcher:
+  - key: Message
+    expr: .*
+  labels:
+    volume: Item[1]
+`)
+	_, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.EqualError(t, err, "configuration for metric 'item' invalid: Can't use 'Item[1]' without an expansion")
+}
+
+func TestConfigErrorItemSubmatchMissing(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: item
+  event_matcher:
+  - key: Message
+    expr: .*
+  expand:
+    source: Message
+    expr: volume (\S+)
+  labels:
+    volume: Item[2]
+`)
+	_, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.EqualError(t, err, "configuration for metric 'item' invalid: Expansion expression does not contain 2 subexpressions")
+}
@@ -16,11 +16,11 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 
-	structs_util "github.com/fatih/structs"
 	"github.com/golang/glog"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -37,78 +37,258 @@ var (
 type FilterMatch struct {
 	Name   string
 	Labels map[string]string
+	// value observed by the metric, 1 for counters without value
+	Value float64
+	// object the event refers to, only set for conditions and latencies, or
+	// the item counted by distinct
+	Object string
+	// whether the event resolves the condition of Object
+	Resolve bool
+	// whether the event starts the latency of Object
+	Start bool
+	// when the event occurred, only set for latencies
+	Timestamp time.Time
 }
 
 func LogEvent(event *v1.Event, er *EventRouter) []FilterMatch {
+	return logEvent(event, eventOccurrences(event), false, er)
+}
+
+// logEvent matches an event against all metrics. Metrics with accounting
+// occurrences count the given occurrences. With checkAge, metrics skip events
+// older than their max_age, or the -discard interval.
+func logEvent(event *v1.Event, occurrences int, checkAge bool, er *EventRouter) []FilterMatch {
 	var matches []FilterMatch
 	eventRouter = er
 	if er.Config == nil {
 		return matches
 	}
 
+	classification := er.Config.Classify(event)
+	objects := &eventObjects{}
+
 OUTER:
-	for _, metric := range er.Config.Metrics {
-		matchResults := make(map[string][]string, len(metric.EventMatcher))
+	for i := range er.Config.Metrics {
+		metric := &er.Config.Metrics[i]
+		if metric.Accounting == AccountingOccurrences && occurrences == 0 {
+			continue OUTER
+		}
+		if checkAge && discardEvent(event, metric.maxAge()) {
+			glog.V(5).Infof("Discarding event for metric '%s': %v", metric.Name, event)
+			continue OUTER
+		}
+		ctx := &LookupContext{Event: event, Matches: make(map[string][]string, len(metric.matchers.matchers)), Classification: classification, metric: metric, objects: objects, occurrences: occurrences}
+		if !metric.matchers.Match(ctx) {
+			if metric.Condition != nil {
+				resolveCtx := &LookupContext{Event: event, Matches: make(map[string][]string, len(metric.Condition.Resolve)), Classification: classification, metric: metric, objects: objects}
+				if metric.Condition.resolve.Match(resolveCtx) {
+					matches = append(matches, FilterMatch{Name: metric.Name, Object: involvedObjectKey(event), Resolve: true})
+				}
+			}
+			if metric.Latency != nil {
+				startCtx := &LookupContext{Event: event, Matches: make(map[string][]string, len(metric.Latency.Start)), Classification: classification, metric: metric, objects: objects}
+				if metric.Latency.start.Match(startCtx) {
+					matches = append(matches, FilterMatch{Name: metric.Name, Object: metric.Latency.objectKey(event), Start: true, Timestamp: EventTimestamp(event)})
+				}
+			}
+			continue OUTER
+		}
 
-		for _, filter := range metric.EventMatcher {
-			value, err := GetValueFromStruct(event, filter.Key)
+		items := []interface{}{nil}
+		if metric.itemsLookup != nil {
+			var err error
+			items, err = metric.itemsLookup(ctx)
 			if err != nil {
-				glog.Errorf("Could not get value for key %s: %v", filter.Key, err)
+				glog.Errorf("Could not expand metric '%s': %v", metric.Name, err)
 				continue OUTER
 			}
+		}
 
-			if filter.Expr != "" {
-				matchResults[filter.Key] = metric.regexMap[filter.Key].FindStringSubmatch(value)
-				glog.V(5).Infof("Expression: %s Value: %s Match: %v\n", filter.Expr, value, matchResults[filter.Key] != nil)
-				if matchResults[filter.Key] == nil {
-					continue OUTER
+	ITEMS:
+		for _, item := range items {
+			ctx.Item = item
+			var l = make(map[string]string)
+
+			for labelKey := range metric.Labels {
+				labelValue, err := metric.labelLookupMap[labelKey](ctx)
+				switch {
+				case err == nil:
+					for _, transform := range metric.labelTransformMap[labelKey] {
+						labelValue = transform(labelValue)
+					}
+				case metric.OnLookupError == OnLookupErrorDefault:
+					glog.V(2).Infof("Using default for label '%s' of metric '%s': %v", labelKey, metric.Name, err)
+					labelValue = metric.Labels[labelKey].Default
+				case metric.OnLookupError == OnLookupErrorCountError:
+					glog.V(2).Infof("Could not get label '%s' for metric '%s': %v", labelKey, metric.Name, err)
+					labelValue = lookupErrorValue
+				default:
+					glog.Errorf("Could not get label '%s' for metric '%s': %v", labelKey, metric.Name, err)
+					droppedMatchesCounter.WithLabelValues(metric.Name, labelKey).Inc()
+					continue ITEMS
 				}
+				l[labelKey] = labelValue
 			}
+
+			value := 1.0
+			if metric.valueLookup != nil {
+				var err error
+				value, err = metric.valueLookup(ctx)
+				if err != nil {
+					glog.Errorf("Could not get value for metric '%s': %v", metric.Name, err)
+					continue ITEMS
+				}
+			}
+
+			match := FilterMatch{Name: metric.Name, Labels: l, Value: value}
+			if metric.Condition != nil {
+				match.Object = involvedObjectKey(event)
+			}
+			if metric.Latency != nil {
+				match.Object = metric.Latency.objectKey(event)
+				match.Timestamp = EventTimestamp(event)
+			}
+			if metric.Distinct != nil {
+				var err error
+				match.Object, err = metric.Distinct.keyLookup(ctx)
+				if err != nil {
+					glog.Errorf("Could not get distinct key for metric '%s': %v", metric.Name, err)
+					continue ITEMS
+				}
+			}
+			matches = append(matches, match)
 		}
+	}
 
-		var l = make(map[string]string)
+	return matches
+}
 
-		for labelKey := range metric.Labels {
-			labelValue, err := metric.labelLookupMap[labelKey](event, matchResults)
-			if err != nil {
-				glog.Errorf("Could not get label '%s' for metric '%s': %v", labelKey, metric.Name, err)
-				continue OUTER
+// Match reports whether the event of ctx matches all matchers of the set and
+// records their submatches in ctx.Matches.
+func (m *matcherSet) Match(ctx *LookupContext) bool {
+	for _, filter := range m.matchers {
+		if values, found := m.keyValuesMap[filter.Key]; found {
+			if !m.matchAny(ctx, filter, values) {
+				return false
 			}
+			continue
+		}
 
-			l[labelKey] = labelValue
+		value, err := m.keyLookupMap[filter.Key](ctx)
+		if err != nil {
+			logLookupError(filter.Key, err)
+			return false
 		}
 
-		matches = append(matches, FilterMatch{Name: metric.Name, Labels: l})
+		if filter.Expr != "" {
+			ctx.Matches[filter.Key] = m.regexMap[filter.Key].FindStringSubmatch(value)
+			glog.V(5).Infof("Expression: %s Value: %s Match: %v\n", filter.Expr, value, ctx.Matches[filter.Key] != nil)
+			if ctx.Matches[filter.Key] == nil {
+				return false
+			}
+		}
 	}
+	return true
+}
 
-	return matches
+// matchAny matches a key with several values, recording the submatches of
+// the first matching value.
+func (m *matcherSet) matchAny(ctx *LookupContext, filter EventMatcher, lookup ValuesFunc) bool {
+	values, err := lookup(ctx)
+	if err != nil {
+		logLookupError(filter.Key, err)
+		return false
+	}
+	if filter.Expr == "" {
+		return true
+	}
+	for _, value := range values {
+		ctx.Matches[filter.Key] = m.regexMap[filter.Key].FindStringSubmatch(value)
+		glog.V(5).Infof("Expression: %s Value: %s Match: %v\n", filter.Expr, value, ctx.Matches[filter.Key] != nil)
+		if ctx.Matches[filter.Key] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// logLookupError logs why the value of a key could not be looked up. Keys
+// parsed from the message fail for all events with a different message, which
+// is no error of the configuration.
+func logLookupError(key string, err error) {
+	if isParsedKey(key) {
+		glog.V(2).Infof("Could not get value for key %s: %v", key, err)
+		return
+	}
+	glog.Errorf("Could not get value for key %s: %v", key, err)
+}
+
+// isParsedKey reports whether the value of a key is parsed from the message.
+func isParsedKey(key string) bool {
+	return strings.HasPrefix(key, MessageFieldsPrefix) || strings.HasPrefix(key, SchedulingVirtualTypePrefix)
 }
 
 func GetValueFromStruct(object interface{}, key string) (string, error) {
-	keySlice := strings.Split(key, ".")
-	s := structs_util.New(object)
-	var newS *structs_util.Field
-	ok := false
-
-	for i, v := range keySlice {
-		if i == 0 {
-			newS, ok = s.FieldOk(v)
-		} else {
-			newS, ok = newS.FieldOk(v)
+	value, err := GetFieldFromStruct(object, key)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatValue(value)
+}
+
+// GetFieldFromStruct returns the value at the path key, which consists of
+// field names separated by dots. Elements of slices and maps are selected
+// by appending their index or key in brackets, e.g. `Spec.Containers[0]`.
+func GetFieldFromStruct(object interface{}, key string) (interface{}, error) {
+	value := reflect.ValueOf(object)
+
+	for i, segment := range splitPath(key) {
+		var err error
+		value, err = indirect(value)
+		if err != nil {
+			return nil, fmt.Errorf("extracting value failed at %s, index %d: %w", segment, i, err)
 		}
 
-		if !ok {
-			return "", fmt.Errorf("extracting value failed at %s, index %d", v, i)
+		if index, ok := strings.CutPrefix(segment, "["); ok {
+			value, err = indexValue(value, strings.TrimSuffix(index, "]"))
+			if err != nil {
+				return nil, fmt.Errorf("extracting value failed at %s, index %d: %w", segment, i, err)
+			}
+			continue
 		}
-	}
 
-	ret, ok := newS.Value().(string)
+		if value.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("extracting value failed at %s, index %d", segment, i)
+		}
+		field, ok := value.Type().FieldByName(segment)
+		if !ok || !field.IsExported() {
+			return nil, fmt.Errorf("extracting value failed at %s, index %d", segment, i)
+		}
+		value = value.FieldByIndex(field.Index)
+	}
 
-	if !ok {
-		return "", errors.New("value is not a string")
+	value, err := indirect(value)
+	if err != nil {
+		return nil, err
 	}
+	return value.Interface(), nil
+}
+
+// eventObjects holds the objects an event refers to once they have been
+// looked up, so that the lookups of all metrics share a single request.
+type eventObjects struct {
+	pod        *v1.Pod
+	podErr     error
+	podFetched bool
+
+	volume        *VolumeInfo
+	volumeErr     error
+	volumeFetched bool
 
-	return ret, nil
+	provider        *ProviderInfo
+	providerErr     error
+	providerFetched bool
 }
 
 func getPodObjectForEvent(event *v1.Event) (*v1.Pod, error) {
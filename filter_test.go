@@ -18,6 +18,8 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -62,10 +64,12 @@ func TestLogEventMatch(t *testing.T) {
 		{
 			Name:   "metric_1",
 			Labels: map[string]string{"node": testEvent.Source.Host, "type": testEvent.Type},
+			Value:  1,
 		},
 		{
 			Name:   "metric_2",
 			Labels: map[string]string{"type": testEvent.Type},
+			Value:  1,
 		},
 	}, matches)
 }
@@ -116,10 +120,63 @@ func TestSkipMetricsWithMissingLabels(t *testing.T) {
 		{
 			Name:   "metric_2",
 			Labels: map[string]string{"type": testEvent.Type},
+			Value:  1,
 		},
 	}, matches)
 }
 
+func TestLabelFallback(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: fallback
+  event_matcher:
+  - key: Message
+    expr: Fallback
+  labels:
+    node: [Object.Spec.NodeName, Source.Host, '"unknown"']
+    component:
+      sources: [Object.Spec.NodeName, Source.Component]
+- name: default
+  on_lookup_error: default
+  event_matcher:
+  - key: Message
+    expr: Fallback
+  labels:
+    node:
+      source: Object.Spec.NodeName
+      default: unknown
+- name: count_error
+  on_lookup_error: count_error
+  event_matcher:
+  - key: Message
+    expr: Fallback
+  labels:
+    node: Object.Spec.NodeName
+- name: drop
+  event_matcher:
+  - key: Message
+    expr: Fallback
+  labels:
+    node: Object.Spec.NodeName
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	testEvent := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "test-namespace", Name: "deleted-pod"},
+		Message:        "Fallback",
+		Source:         v1.EventSource{Component: "kubelet"},
+	}
+	dropped := counterValue(t, droppedMatchesCounter, "drop", "node")
+	matches := LogEvent(&testEvent, &EventRouter{Config: config, kubeClient: fake.NewSimpleClientset()})
+
+	require.Equal(t, []FilterMatch{
+		{Name: "fallback", Labels: map[string]string{"node": "unknown", "component": "kubelet"}, Value: 1},
+		{Name: "default", Labels: map[string]string{"node": "unknown"}, Value: 1},
+		{Name: "count_error", Labels: map[string]string{"node": "lookup_error"}, Value: 1},
+	}, matches)
+	require.Equal(t, dropped+1, counterValue(t, droppedMatchesCounter, "drop", "node"))
+}
+
 func TestLabelSubmatch(t *testing.T) {
 	testConfig := []byte(`metrics:
 - name: submatch
@@ -145,6 +202,35 @@ func TestLabelSubmatch(t *testing.T) {
 		{
 			Name:   "submatch",
 			Labels: map[string]string{"volume": "vol-1234", "instance": "instance-789"},
+			Value:  1,
+		},
+	}, matches)
+}
+
+func TestLabelNamedSubmatch(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: submatch
+  event_matcher:
+  - key: Message
+    expr: Volume (?P<volume>.*) mount failed for Instance (?P<instance>.*) (a)(b)(c)(d)(e)(f)(g)(h)(i)(j)
+  labels:
+    volume: Message[volume]
+    instance: Message[instance]
+    tenth: Message[10]
+`)
+	testEvent := v1.Event{
+		Message: "Volume vol-1234 mount failed for Instance instance-789 abcdefghij",
+	}
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+
+	require.Equal(t, []FilterMatch{
+		{
+			Name:   "submatch",
+			Labels: map[string]string{"volume": "vol-1234", "instance": "instance-789", "tenth": "h"},
+			Value:  1,
 		},
 	}, matches)
 }
@@ -182,7 +268,7 @@ func TestObjectReference(t *testing.T) {
 
 	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fakeClient})
 	require.Equal(t, []FilterMatch{
-		{Name: "submatch", Labels: map[string]string{"node": pod.Spec.NodeName}},
+		{Name: "submatch", Labels: map[string]string{"node": pod.Spec.NodeName}, Value: 1},
 	}, matches)
 }
 
@@ -211,6 +297,19 @@ func TestConfigErrorSubmatchGroupMissing(t *testing.T) {
 	require.EqualError(t, err, "configuration for metric 'submatch' invalid: Match expression for key 'Message' does not contain 1 subexpressions")
 }
 
+func TestConfigErrorNamedSubmatchMissing(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: submatch
+  event_matcher:
+  - key: Message
+    expr: Volume (?P<volume>.*) mount failed
+  labels:
+    instance: Message[instance]
+`)
+	_, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.EqualError(t, err, "configuration for metric 'submatch' invalid: Match expression for key 'Message' does not contain a group named 'instance'")
+}
+
 func TestLogEventEmptyConfig(t *testing.T) {
 	matches := LogEvent(&v1.Event{}, &EventRouter{})
 
@@ -229,3 +328,173 @@ func TestLogEventEmptyEvent(t *testing.T) {
 	matches := LogEvent(&testEvent, &EventRouter{})
 	require.Equal(t, 0, len(matches), "There should be no metrics returned")
 }
+
+func TestVolumeReference(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: volume
+  event_matcher:
+  - key: Reason
+    expr: FailedMount
+  labels:
+    pvc: Volume.PVC
+    pv: Volume.PV
+    storage_class: Volume.StorageClass
+    driver: Volume.Driver
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	storageClass := "fast"
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec: v1.PodSpec{Volumes: []v1.Volume{
+			{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{}}},
+			{Name: "cache", VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "cache-claim"}}},
+			{Name: "data", VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "data-claim"}}},
+		}},
+	}
+	cachePVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "cache-claim", Namespace: "test-namespace"},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-cache", StorageClassName: &storageClass},
+	}
+	dataPVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-claim", Namespace: "test-namespace"},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-data", StorageClassName: &storageClass},
+	}
+	cachePV := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-cache"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{Driver: "cinder.csi.openstack.org"},
+		}},
+	}
+	dataPV := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{Driver: "cinder.csi.openstack.org"},
+		}},
+	}
+
+	fakeClient := fake.NewSimpleClientset(pod, cachePVC, dataPVC, cachePV, dataPV)
+	event := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+		Reason:         "FailedMount",
+		Message:        `MountVolume.SetUp failed for volume "pv-data" : rpc error: code = Internal`,
+	}
+
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fakeClient})
+	require.Equal(t, []FilterMatch{
+		{Name: "volume", Labels: map[string]string{
+			"pvc":           "data-claim",
+			"pv":            "pv-data",
+			"storage_class": "fast",
+			"driver":        "cinder.csi.openstack.org",
+		}, Value: 1},
+	}, matches)
+	// the pod, both claims and the PV of the chain are looked up once for all labels
+	require.Len(t, fakeClient.Actions(), 4)
+
+	// claims of other volumes which no longer exist must not hide the named volume
+	pod.Spec.Volumes = append([]v1.Volume{
+		{Name: "gone", VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "gone-claim"}}},
+	}, pod.Spec.Volumes...)
+	fakeClient = fake.NewSimpleClientset(pod, cachePVC, dataPVC, cachePV, dataPV)
+	event.Message = "Unable to attach or mount volumes: unmounted volumes=[data], unattached volumes=[gone cache data]"
+
+	matches = LogEvent(&event, &EventRouter{Config: config, kubeClient: fakeClient})
+	require.Equal(t, []FilterMatch{
+		{Name: "volume", Labels: map[string]string{
+			"pvc":           "data-claim",
+			"pv":            "pv-data",
+			"storage_class": "fast",
+			"driver":        "cinder.csi.openstack.org",
+		}, Value: 1},
+	}, matches)
+}
+
+func TestVolumeReferenceWithoutStorageClass(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: volume
+  event_matcher:
+  - key: Reason
+    expr: ProvisioningFailed
+  labels:
+    pvc: Volume.PVC
+    storage_class: Volume.StorageClass
+    driver: Volume.Driver
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	storageClass := "deleted"
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-claim", Namespace: "test-namespace"},
+		Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &storageClass},
+	}
+	event := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name},
+		Reason:         "ProvisioningFailed",
+	}
+
+	// the driver is optional, the rest of the chain is still resolved
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fake.NewSimpleClientset(pvc)})
+	require.Equal(t, []FilterMatch{
+		{Name: "volume", Labels: map[string]string{"pvc": "data-claim", "storage_class": "deleted", "driver": ""}, Value: 1},
+	}, matches)
+}
+
+func TestContainerReference(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: backoff
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+  labels:
+    container: Container.Name
+    image: Container.Image
+    tag: Container.ImageTag
+    restarts: Container.RestartCount
+    reason: Container.LastTerminationReason
+    exit_code: Container.LastTerminationExitCode
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "app", Image: "registry.example.com:5000/app:1.0"},
+			{Name: "sidecar", Image: "keppel.example.com/proxy:v2.3"},
+		}},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+			{Name: "app"},
+			{Name: "sidecar", RestartCount: 7, LastTerminationState: v1.ContainerState{
+				Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+			}},
+		}},
+	}
+
+	fakeClient := fake.NewSimpleClientset(pod)
+	event := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, FieldPath: "spec.containers{sidecar}"},
+		Reason:         "BackOff",
+	}
+
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fakeClient})
+	require.Equal(t, []FilterMatch{
+		{Name: "backoff", Labels: map[string]string{
+			"container": "sidecar",
+			"image":     "keppel.example.com/proxy:v2.3",
+			"tag":       "v2.3",
+			"restarts":  "7",
+			"reason":    "OOMKilled",
+			"exit_code": "137",
+		}, Value: 1},
+	}, matches)
+}
+
+func counterValue(t *testing.T, vec *prometheus.CounterVec, labels ...string) float64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, vec.WithLabelValues(labels...).Write(&m))
+	return m.GetCounter().GetValue()
+}
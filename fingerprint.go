@@ -0,0 +1,90 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	MessageFingerprintKey     = "MessageFingerprint"
+	MessageFingerprintHashKey = "MessageFingerprint.Hash"
+	fingerprintHashLength     = 8
+)
+
+// generated name suffixes of Kubernetes, e.g. `-7d9f8b6c5d-x2k4p` of a pod of a
+// Deployment, use an alphabet without vowels and easily confused characters
+const generatedSuffix = `[bcdfghjklmnpqrstvwxz2456789]`
+
+var (
+	// matches single durations in minutes, e.g. `500m`, which are usually quantities
+	bareMinutesRE = regexp.MustCompile(`^\d+(?:\.\d+)?m$`)
+)
+
+// fingerprintRules replace the variable parts of event messages with
+// placeholders, which may refer to submatches. They are applied in order, so
+// more specific patterns have to come before the ones they contain (e.g.
+// UUIDs before hex IDs and numbers).
+var fingerprintRules = []struct {
+	re          *regexp.Regexp
+	placeholder string
+	filter      func(match string) bool
+}{
+	{re: regexp.MustCompile(`"[^"]*"`), placeholder: `"<*>"`},
+	{re: regexp.MustCompile(`'[^']*'`), placeholder: `'<*>'`},
+	// pods as referred to by the kubelet, e.g. `nginx-7d9f8b6c5d-x2k4p_default(<uid>)`
+	{re: regexp.MustCompile(`\b[a-z0-9](?:[-a-z0-9.]*[a-z0-9])?_[a-z0-9](?:[-a-z0-9]*[a-z0-9])?\([0-9a-fA-F-]+\)`), placeholder: "<pod>"},
+	{re: regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), placeholder: "<uuid>"},
+	{re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+|/\d{1,2})?\b`), placeholder: "<ip>"},
+	{re: regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){4,7}[0-9a-fA-F]{1,4}\b|\b(?:[0-9a-fA-F]{1,4}:)+:(?:[0-9a-fA-F]{1,4}(?::[0-9a-fA-F]{1,4})*)?\b`), placeholder: "<ip>"},
+	// namespaced objects, e.g. `default/nginx-7d9f8b6c5d-x2k4p`
+	{re: regexp.MustCompile(`(^|[\s(\[,:=])[a-z][-a-z0-9]*/[a-z0-9](?:[-.a-z0-9]*[a-z0-9])?\b`), placeholder: "${1}<namespace>/<name>"},
+	// generated names of pods and ReplicaSets, e.g. `nginx-7d9f8b6c5d-x2k4p` or `nginx-7d9f8b6c5d`
+	{re: regexp.MustCompile(`\b[a-z0-9](?:[-a-z0-9]*[a-z0-9])?-(?:(?:` + generatedSuffix + `{6,10}-)?` + generatedSuffix + `{5}|` + generatedSuffix + `{9,10})\b`), placeholder: "<name>"},
+	{re: regexp.MustCompile(`\b(?:\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h))+\b`), placeholder: "<duration>", filter: func(match string) bool {
+		return !bareMinutesRE.MatchString(match)
+	}},
+	{re: regexp.MustCompile(`\b\d+(?:\.\d+)?(?:[kMGTPE]i?|Ki|m)\b`), placeholder: "<quantity>"},
+	{re: regexp.MustCompile(`\b[0-9a-fA-F]{8,}\b`), placeholder: "<hex>", filter: func(match string) bool {
+		// long words made up of the letters a-f only are no IDs
+		return strings.ContainsAny(match, "0123456789")
+	}},
+	{re: regexp.MustCompile(`\b\d+(?:\.\d+)?\b`), placeholder: "<num>"},
+}
+
+// FingerprintMessage normalizes an event message into a template by
+// replacing quoted names, object references, generated names, UUIDs, IP
+// addresses, durations, quantities, hex IDs and numbers with placeholders, so similar messages yield the same label value.
+func FingerprintMessage(message string) string {
+	for _, rule := range fingerprintRules {
+		if rule.filter == nil {
+			message = rule.re.ReplaceAllString(message, rule.placeholder)
+			continue
+		}
+		message = rule.re.ReplaceAllStringFunc(message, func(match string) string {
+			if rule.filter(match) {
+				return rule.placeholder
+			}
+			return match
+		})
+	}
+	return message
+}
+
+// FingerprintMessageHash returns a short, stable hash of the message template.
+func FingerprintMessageHash(message string) string {
+	return sha1Hash(FingerprintMessage(message))[:fingerprintHashLength]
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprintMessage(t *testing.T) {
+	for message, expected := range map[string]string{
+		`MountVolume.SetUp failed for volume "pvc-1234" : rpc error: code = Internal desc = timed out after 2m0s`:              `MountVolume.SetUp failed for volume "<*>" : rpc error: code = Internal desc = timed out after <duration>`,
+		`Readiness probe failed: Get "http://10.0.3.17:8080/healthz": dial tcp 10.0.3.17:8080: connect: connection refused`:    `Readiness probe failed: Get "<*>": dial tcp <ip>: connect: connection refused`,
+		`Pod sandbox 3f2a9c1e-7b4d-4e8a-9f0c-1d2e3f4a5b6c changed, it will be killed and re-created.`:                          `Pod sandbox <uuid> changed, it will be killed and re-created.`,
+		`Container image sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945 already present`:              `Container image sha256:<hex> already present`,
+		`Back-off restarting failed container app in pod nginx-7d9f8b6c5d-x2k4p_default(0b5a6c1e-4f3d-4a2b-9c8d-7e6f5a4b3c2d)`: `Back-off restarting failed container app in pod <pod>`,
+		`Successfully assigned default/nginx-7d9f8b6c5d-x2k4p to node-10-0-1-5`:                                                `Successfully assigned <namespace>/<name> to node-<num>-<num>-<num>-<num>`,
+		`Created pod: nginx-7d9f8b6c5d-x2k4p`:                                           `Created pod: <name>`,
+		`Created pod: backup-28459320-x7kzq`:                                            `Created pod: <name>`,
+		`Stopping container kube-proxy`:                                                 `Stopping container kube-proxy`,
+		`Requested 500m CPU and 512Mi memory`:                                           `Requested <quantity> CPU and <quantity> memory`,
+		`0/12 nodes are available: 3 Insufficient cpu, 9 node(s) had untolerated taint`: `<num>/<num> nodes are available: <num> Insufficient cpu, <num> node(s) had untolerated taint`,
+		`Node fe80::1ff:fe23:4567:890a is not ready since 1.5s`:                         `Node <ip> is not ready since <duration>`,
+		`Scaled up replica set nginx-7d9f8b6c5d to 3`:                                   `Scaled up replica set <name> to <num>`,
+		`Scaled up replica set deadbeef to 3`:                                           `Scaled up replica set deadbeef to <num>`,
+	} {
+		require.Equal(t, expected, FingerprintMessage(message), message)
+	}
+
+	require.Equal(t,
+		FingerprintMessageHash(`Liveness probe failed: HTTP probe failed with statuscode: 500`),
+		FingerprintMessageHash(`Liveness probe failed: HTTP probe failed with statuscode: 503`))
+	require.Len(t, FingerprintMessageHash("message"), 8)
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	ImageVirtualTypePrefix = "Image."
+	defaultRegistry        = "docker.io"
+)
+
+var (
+	// matches e.g. `Failed to pull image "nginx:1.27": ...` or `Back-off pulling image "nginx:1.27"`
+	messageImageRE = regexp.MustCompile(`image "([^"]+)"`)
+)
+
+// ImageReference is an image reference split into its parts. Its fields are
+// exposed as `Image.<Field>` labels.
+type ImageReference struct {
+	// normalized reference, e.g. docker.io/library/nginx:latest
+	Reference  string
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageReference splits an image reference following the normalization
+// rules of Docker: references without registry refer to docker.io, where
+// official images live below library/, and untagged references refer to the
+// latest tag unless they are pinned by digest.
+func ParseImageReference(image string) *ImageReference {
+	ref := &ImageReference{}
+	name, digest, pinned := strings.Cut(image, "@")
+	if pinned {
+		ref.Digest = digest
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	} else if !pinned {
+		ref.Tag = "latest"
+	}
+
+	registry, repository, found := strings.Cut(name, "/")
+	if !found || !(strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		registry, repository = defaultRegistry, name
+	}
+	if registry == "index.docker.io" {
+		registry = defaultRegistry
+	}
+	if registry == defaultRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	ref.Registry, ref.Repository = registry, repository
+
+	ref.Reference = registry + "/" + repository
+	if ref.Tag != "" {
+		ref.Reference += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		ref.Reference += "@" + ref.Digest
+	}
+	return ref
+}
+
+// getImageReferenceForEvent takes the image reference from the message, or
+// from the spec of the container the event refers to.
+func getImageReferenceForEvent(ctx *LookupContext) (*ImageReference, error) {
+	if match := messageImageRE.FindStringSubmatch(ctx.Event.Message); match != nil {
+		return ParseImageReference(match[1]), nil
+	}
+	container, err := getContainerInfoForEvent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseImageReference(container.Image), nil
+}
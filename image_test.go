@@ -0,0 +1,84 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseImageReference(t *testing.T) {
+	for image, expected := range map[string]ImageReference{
+		"nginx":                   {Reference: "docker.io/library/nginx:latest", Registry: "docker.io", Repository: "library/nginx", Tag: "latest"},
+		"bitnami/redis:7.2":       {Reference: "docker.io/bitnami/redis:7.2", Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.2"},
+		"index.docker.io/nginx:1": {Reference: "docker.io/library/nginx:1", Registry: "docker.io", Repository: "library/nginx", Tag: "1"},
+		"localhost/app":           {Reference: "localhost/app:latest", Registry: "localhost", Repository: "app", Tag: "latest"},
+		"registry.example.com:5000/team/app:1.0@sha256:abc": {
+			Reference: "registry.example.com:5000/team/app:1.0@sha256:abc", Registry: "registry.example.com:5000", Repository: "team/app", Tag: "1.0", Digest: "sha256:abc",
+		},
+		"keppel.example.com/app@sha256:abc": {
+			Reference: "keppel.example.com/app@sha256:abc", Registry: "keppel.example.com", Repository: "app", Digest: "sha256:abc",
+		},
+	} {
+		require.Equal(t, &expected, ParseImageReference(image), image)
+	}
+}
+
+func TestImageReference(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: image_pull
+  event_matcher:
+  - key: Reason
+    expr: Failed|BackOff
+  labels:
+    registry: Image.Registry
+    repository: Image.Repository
+    tag: Image.Tag
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "quay.io/team/app:2.0"}}},
+	}
+	fakeClient := fake.NewSimpleClientset(pod)
+	involvedObject := v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, FieldPath: "spec.containers{app}"}
+
+	event := v1.Event{
+		InvolvedObject: involvedObject,
+		Reason:         "Failed",
+		Message:        `Failed to pull image "nginx:1.27": rpc error: code = NotFound`,
+	}
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fakeClient})
+	require.Equal(t, []FilterMatch{
+		{Name: "image_pull", Labels: map[string]string{"registry": "docker.io", "repository": "library/nginx", "tag": "1.27"}, Value: 1},
+	}, matches)
+
+	event = v1.Event{
+		InvolvedObject: involvedObject,
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+	}
+	matches = LogEvent(&event, &EventRouter{Config: config, kubeClient: fakeClient})
+	require.Equal(t, []FilterMatch{
+		{Name: "image_pull", Labels: map[string]string{"registry": "quay.io", "repository": "team/app", "tag": "2.0"}, Value: 1},
+	}, matches)
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// timeout of pending starts of latencies without explicit timeout
+const defaultLatencyTimeout = time.Hour
+
+// LatencyConfig turns a metric into a histogram of the time between an event
+// matching Start and an event about the same object matching End.
+type LatencyConfig struct {
+	Start []EventMatcher `yaml:"start"`
+	End   []EventMatcher `yaml:"end"`
+	// correlate events by InvolvedObject.FieldPath as well, e.g. per container
+	ByFieldPath bool          `yaml:"by_field_path"`
+	Timeout     time.Duration `yaml:"timeout"`
+	start       *matcherSet
+}
+
+// compileLatency validates the latency of a metric and compiles its start
+// matchers. The end matchers are compiled as the metric's matchers.
+func compileLatency(metric *MetricConfig) error {
+	latency := metric.Latency
+	if len(metric.EventMatcher) > 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: Can't use event_matcher together with a latency, use end instead", metric.Name)
+	}
+	if (metric.Type != "" && metric.Type != MetricTypeHistogram) || metric.Value != "" || metric.Accounting != "" || metric.Window != 0 || metric.Objectives != nil {
+		return fmt.Errorf("configuration for metric '%s' invalid: Can't use type, value, accounting, window or objectives together with a latency", metric.Name)
+	}
+	metric.Type = MetricTypeHistogram
+	if err := validateBuckets(metric); err != nil {
+		return err
+	}
+	if len(latency.Start) == 0 || len(latency.End) == 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: latency needs start and end matchers", metric.Name)
+	}
+	switch {
+	case latency.Timeout < 0:
+		return fmt.Errorf("configuration for metric '%s' invalid: latency timeout must not be negative", metric.Name)
+	case latency.Timeout == 0:
+		latency.Timeout = defaultLatencyTimeout
+	}
+
+	var err error
+	latency.start, err = newMatcherSet(metric, latency.Start)
+	if err != nil {
+		return fmt.Errorf("configuration for metric '%s' invalid: start matchers invalid: %w", metric.Name, err)
+	}
+	return nil
+}
+
+// objectKey identifies the object of an event for correlating it with other
+// events of the same metric.
+func (latency *LatencyConfig) objectKey(event *v1.Event) string {
+	if latency.ByFieldPath {
+		return involvedObjectKey(event) + "/" + event.InvolvedObject.FieldPath
+	}
+	return involvedObjectKey(event)
+}
+
+// latencyVec is a histogram of the time between the start and end events of
+// objects. Labels are taken from the end event. Only the first start before
+// an end counts, and starts without end expire after the timeout.
+type latencyVec struct {
+	*prometheus.HistogramVec
+	timeout time.Duration
+	now     func() time.Time
+
+	mu         sync.Mutex
+	pending    map[string]time.Time
+	lastExpiry time.Time
+}
+
+func newLatencyVec(opts prometheus.HistogramOpts, labels []string, timeout time.Duration) *latencyVec {
+	return &latencyVec{
+		HistogramVec: prometheus.NewHistogramVec(opts, labels),
+		timeout:      timeout,
+		now:          time.Now,
+		pending:      make(map[string]time.Time),
+	}
+}
+
+// Update records the start of a latency or observes it at its end.
+func (v *latencyVec) Update(match FilterMatch) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.expire()
+
+	if match.Start {
+		if _, found := v.pending[match.Object]; !found {
+			v.pending[match.Object] = match.Timestamp
+		}
+		return nil
+	}
+
+	start, found := v.pending[match.Object]
+	if !found {
+		return nil
+	}
+	delete(v.pending, match.Object)
+	latency := match.Timestamp.Sub(start)
+	if latency < 0 || latency > v.timeout {
+		return fmt.Errorf("dropping latency of %s for object %s", latency, match.Object)
+	}
+	observer, err := v.GetMetricWith(match.Labels)
+	if err != nil {
+		return err
+	}
+	observer.Observe(latency.Seconds())
+	return nil
+}
+
+// expire drops pending starts older than the timeout. As this has to look at
+// all of them, it is done at most once per minute.
+func (v *latencyVec) expire() {
+	now := v.now()
+	if now.Sub(v.lastExpiry) < time.Minute {
+		return
+	}
+	v.lastExpiry = now
+	for object, start := range v.pending {
+		if now.Sub(start) > v.timeout {
+			delete(v.pending, object)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLatency(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: image_pull_seconds
+  latency:
+    start:
+    - key: Reason
+      expr: Pulling
+    end:
+    - key: Reason
+      expr: Pulled
+    by_field_path: true
+    timeout: 10m
+  buckets: [1, 10, 60]
+  labels:
+    namespace: InvolvedObject.Namespace
+`))
+	require.NoError(t, err)
+	vec := newMetricCollector(&config.Metrics[0], []string{"namespace"}).(*latencyVec)
+	kubernetesEventMetricVec = map[string]prometheus.Collector{"image_pull_seconds": vec}
+	router := &EventRouter{Config: config}
+
+	now := time.Now()
+	app := v1.ObjectReference{Namespace: "default", UID: "1234", FieldPath: "spec.containers{app}"}
+	sidecar := v1.ObjectReference{Namespace: "default", UID: "1234", FieldPath: "spec.containers{sidecar}"}
+	events := []v1.Event{
+		{Reason: "Pulling", InvolvedObject: app, LastTimestamp: metav1.NewTime(now)},
+		{Reason: "Pulling", InvolvedObject: sidecar, LastTimestamp: metav1.NewTime(now.Add(time.Second))},
+		// only the first start counts
+		{Reason: "Pulling", InvolvedObject: app, LastTimestamp: metav1.NewTime(now.Add(2 * time.Second))},
+		{Reason: "Pulled", InvolvedObject: app, LastTimestamp: metav1.NewTime(now.Add(5 * time.Second))},
+		{Reason: "Pulled", InvolvedObject: sidecar, LastTimestamp: metav1.NewTime(now.Add(31 * time.Second))},
+		// end without start
+		{Reason: "Pulled", InvolvedObject: app, LastTimestamp: metav1.NewTime(now.Add(40 * time.Second))},
+	}
+	require.Equal(t, []FilterMatch{
+		{Name: "image_pull_seconds", Object: "1234/spec.containers{app}", Start: true, Timestamp: now},
+	}, LogEvent(&events[0], router))
+	for i := range events {
+		for _, match := range LogEvent(&events[i], router) {
+			prometheusEvent(match)
+		}
+	}
+
+	var m dto.Metric
+	observer, err := vec.GetMetricWithLabelValues("default")
+	require.NoError(t, err)
+	require.NoError(t, observer.(prometheus.Metric).Write(&m))
+	require.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	require.InDelta(t, 35, m.GetHistogram().GetSampleSum(), 0.001)
+	require.Equal(t, uint64(1), m.GetHistogram().GetBucket()[1].GetCumulativeCount())
+}
+
+func TestLatencyTimeout(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	vec := newLatencyVec(prometheus.HistogramOpts{Name: "latency", Help: "help"}, nil, 10*time.Minute)
+	vec.now = func() time.Time { return now }
+
+	require.NoError(t, vec.Update(FilterMatch{Object: "a", Start: true, Timestamp: now}))
+	require.NoError(t, vec.Update(FilterMatch{Object: "b", Start: true, Timestamp: now}))
+	require.Error(t, vec.Update(FilterMatch{Object: "a", Timestamp: now.Add(time.Hour)}))
+
+	now = now.Add(time.Hour)
+	require.NoError(t, vec.Update(FilterMatch{Object: "c", Start: true, Timestamp: now}))
+	require.Equal(t, map[string]time.Time{"c": now}, vec.pending)
+}
+
+func TestConfigErrorLatency(t *testing.T) {
+	for testConfig, expected := range map[string]string{
+		"metrics:\n- name: l\n  latency:\n    start:\n    - key: Reason\n":                                                 "configuration for metric 'l' invalid: latency needs start and end matchers",
+		"metrics:\n- name: l\n  type: summary\n  latency:\n    start:\n    - key: Reason\n    end:\n    - key: Reason\n":   "configuration for metric 'l' invalid: Can't use type, value, accounting, window or objectives together with a latency",
+		"metrics:\n- name: l\n  buckets: [2, 1]\n  latency:\n    start:\n    - key: Reason\n    end:\n    - key: Reason\n": "configuration for metric 'l' invalid: buckets have to be in increasing order",
+	} {
+		_, err := NewConfig(bytes.NewBufferString(testConfig))
+		require.EqualError(t, err, expected)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	yaml "gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// matches e.g. `lookup(teams, InvolvedObject.Namespace, "unowned")`
+	lookupLabelRE = regexp.MustCompile(`^lookup\(\s*([\w-]+)(?:\.([\w-]+))?\s*,\s*(.+?)\s*(?:,\s*("(?:[^"\\]|\\.)*"))?\s*\)$`)
+)
+
+// LookupTableConfig declares a table which maps keys to one or more values,
+// loaded either from a file or from a ConfigMap. Tables are given as YAML
+// (mapping keys to a value or to a mapping of column names to values) or as
+// CSV with a header row, where the first column contains the keys.
+type LookupTableConfig struct {
+	Name      string `yaml:"name"`
+	File      string `yaml:"file"`
+	ConfigMap *struct {
+		Namespace string `yaml:"namespace"`
+		Name      string `yaml:"name"`
+		// data key holding the table, without key the data itself is the table
+		Key string `yaml:"key"`
+	} `yaml:"config_map"`
+}
+
+type LookupTable struct {
+	config LookupTableConfig
+
+	mu      sync.RWMutex
+	columns []string
+	rows    map[string][]string
+	// modification time of the file or resource version of the ConfigMap
+	version string
+}
+
+func newLookupTable(config LookupTableConfig) (*LookupTable, error) {
+	if config.Name == "" {
+		return nil, errors.New("lookup table without name")
+	}
+	if (config.File == "") == (config.ConfigMap == nil) {
+		return nil, fmt.Errorf("lookup table '%s' needs either a file or a config_map", config.Name)
+	}
+	return &LookupTable{config: config}, nil
+}
+
+// Lookup returns the value of column for key. An empty column refers to the
+// first column of the table.
+func (t *LookupTable) Lookup(key, column string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	row, found := t.rows[key]
+	if !found {
+		return "", false
+	}
+	if column == "" && len(row) > 0 {
+		return row[0], true
+	}
+	for i, c := range t.columns {
+		if c == column && i < len(row) {
+			return row[i], true
+		}
+	}
+	return "", false
+}
+
+// Load (re)loads the table if its source has changed. ConfigMaps are read
+// through kubeClient, which may be nil for tables loaded from files.
+func (t *LookupTable) Load(kubeClient kubernetes.Interface) error {
+	var data []byte
+	var version, format string
+
+	if t.config.File != "" {
+		info, err := os.Stat(t.config.File)
+		if err != nil {
+			return err
+		}
+		version = info.ModTime().String()
+		if version == t.currentVersion() {
+			return nil
+		}
+		data, err = os.ReadFile(t.config.File)
+		if err != nil {
+			return err
+		}
+		format = filepath.Ext(t.config.File)
+	} else {
+		if kubeClient == nil {
+			return errors.New("no kubernetes client for loading ConfigMap")
+		}
+		cmConfig := t.config.ConfigMap
+		cm, err := kubeClient.CoreV1().ConfigMaps(cmConfig.Namespace).Get(context.TODO(), cmConfig.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		version = cm.ResourceVersion
+		if version == t.currentVersion() {
+			return nil
+		}
+		if cmConfig.Key == "" {
+			t.set(version, []string{"value"}, toRows(cm.Data))
+			return nil
+		}
+		content, found := cm.Data[cmConfig.Key]
+		if !found {
+			return fmt.Errorf("key '%s' not found in ConfigMap %s/%s", cmConfig.Key, cmConfig.Namespace, cmConfig.Name)
+		}
+		data = []byte(content)
+		format = filepath.Ext(cmConfig.Key)
+	}
+
+	columns, rows, err := parseLookupTable(data, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse lookup table '%s': %w", t.config.Name, err)
+	}
+	t.set(version, columns, rows)
+	return nil
+}
+
+func (t *LookupTable) currentVersion() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.version
+}
+
+func (t *LookupTable) set(version string, columns []string, rows map[string][]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.version, t.columns, t.rows = version, columns, rows
+	glog.Infof("Loaded lookup table '%s' with %d entries", t.config.Name, len(rows))
+}
+
+func toRows(data map[string]string) map[string][]string {
+	rows := make(map[string][]string, len(data))
+	for key, value := range data {
+		rows[key] = []string{value}
+	}
+	return rows
+}
+
+func parseLookupTable(data []byte, format string) ([]string, map[string][]string, error) {
+	if format == ".csv" {
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(records) == 0 || len(records[0]) < 2 {
+			return nil, nil, errors.New("CSV needs a header row with a key column and at least one value column")
+		}
+		rows := make(map[string][]string, len(records)-1)
+		for _, record := range records[1:] {
+			rows[record[0]] = record[1:]
+		}
+		return records[0][1:], rows, nil
+	}
+
+	var values map[string]string
+	if err := yaml.Unmarshal(data, &values); err == nil {
+		return []string{"value"}, toRows(values), nil
+	}
+	var records map[string]map[string]string
+	if err := yaml.Unmarshal(data, &records); err != nil {
+		return nil, nil, err
+	}
+	columnSet := make(map[string]bool)
+	for _, record := range records {
+		for column := range record {
+			columnSet[column] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 && len(records) > 0 {
+		return nil, nil, errors.New("YAML table needs at least one value column")
+	}
+	sort.Strings(columns)
+	rows := make(map[string][]string, len(records))
+	for key, record := range records {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = record[column]
+		}
+		rows[key] = row
+	}
+	return columns, rows, nil
+}
+
+// LoadLookupTables loads all tables of the configuration and reloads them
+// in the given interval until stopCh is closed.
+func (config *Config) LoadLookupTables(kubeClient kubernetes.Interface, interval time.Duration, stopCh <-chan struct{}) error {
+	for _, table := range config.lookupTables {
+		if err := table.Load(kubeClient); err != nil {
+			return fmt.Errorf("failed to load lookup table '%s': %w", table.config.Name, err)
+		}
+	}
+	if len(config.lookupTables) == 0 || interval == 0 {
+		return nil
+	}
+	go wait.Until(func() {
+		for _, table := range config.lookupTables {
+			if err := table.Load(kubeClient); err != nil {
+				glog.Errorf("Failed to reload lookup table '%s': %v", table.config.Name, err)
+			}
+		}
+	}, interval, stopCh)
+	return nil
+}
+
+// newTableLookup creates the lookup for a label given as
+// `lookup(<table>[.<column>], <source>[, "<default>"])`.
+func newTableLookup(metric *MetricConfig, labelSpec string) (LookupFunc, error) {
+	match := lookupLabelRE.FindStringSubmatch(labelSpec)
+	if match == nil {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: Can't parse lookup '%s'", metric.Name, labelSpec)
+	}
+	table, found := metric.lookupTables[match[1]]
+	if !found {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: Unknown lookup table '%s'", metric.Name, match[1])
+	}
+	column := match[2]
+	source, err := newLabelLookup(metric, match[3])
+	if err != nil {
+		return nil, err
+	}
+	var def *string
+	if match[4] != "" {
+		value, err := strconv.Unquote(match[4])
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: default of lookup '%s' invalid: %w", metric.Name, labelSpec, err)
+		}
+		def = &value
+	}
+
+	return func(ctx *LookupContext) (string, error) {
+		key, err := source(ctx)
+		if err != nil {
+			return "", err
+		}
+		if value, found := table.Lookup(key, column); found {
+			return value, nil
+		}
+		if def != nil {
+			return *def, nil
+		}
+		return "", fmt.Errorf("key '%s' not found in lookup table '%s'", key, table.config.Name)
+	}, nil
+}
+
+func isTableLookup(labelSpec string) bool {
+	return strings.HasPrefix(labelSpec, "lookup(")
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLookupTables(t *testing.T) {
+	dir := t.TempDir()
+	teamsFile := filepath.Join(dir, "teams.yaml")
+	require.NoError(t, os.WriteFile(teamsFile, []byte("kube-system: infra\nmonitoring: observability\n"), 0o600))
+	ownersFile := filepath.Join(dir, "owners.csv")
+	require.NoError(t, os.WriteFile(ownersFile, []byte("namespace,team,cost_center\nkube-system,infra,CC-100\n"), 0o600))
+
+	testConfig := []byte(`lookup_tables:
+- name: teams
+  file: ` + teamsFile + `
+- name: owners
+  file: ` + ownersFile + `
+- name: escalation
+  config_map:
+    namespace: kube-system
+    name: escalation
+metrics:
+- name: lookup
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+  labels:
+    team: lookup(teams, InvolvedObject.Namespace, "unowned")
+    cost_center: lookup(owners.cost_center, InvolvedObject.Namespace)
+    escalation: lookup(escalation, lookup(teams, InvolvedObject.Namespace), "none")
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	fakeClient := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "escalation", Namespace: "kube-system", ResourceVersion: "1"},
+		Data:       map[string]string{"infra": "page"},
+	})
+	require.NoError(t, config.LoadLookupTables(fakeClient, 0, nil))
+
+	event := v1.Event{InvolvedObject: v1.ObjectReference{Namespace: "kube-system"}, Reason: "BackOff"}
+	matches := LogEvent(&event, &EventRouter{Config: config})
+	require.Equal(t, []FilterMatch{
+		{Name: "lookup", Labels: map[string]string{"team": "infra", "cost_center": "CC-100", "escalation": "page"}, Value: 1},
+	}, matches)
+
+	// without default, a missing key drops the match
+	event = v1.Event{InvolvedObject: v1.ObjectReference{Namespace: "default"}, Reason: "BackOff"}
+	require.Empty(t, LogEvent(&event, &EventRouter{Config: config}))
+
+	// changed files are reloaded
+	require.NoError(t, os.WriteFile(teamsFile, []byte("kube-system: platform\n"), 0o600))
+	require.NoError(t, os.Chtimes(teamsFile, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, config.lookupTables["teams"].Load(nil))
+	value, found := config.lookupTables["teams"].Lookup("kube-system", "")
+	require.True(t, found)
+	require.Equal(t, "platform", value)
+}
+
+func TestLookupTableWithoutColumns(t *testing.T) {
+	_, _, err := parseLookupTable([]byte("kube-system: {}\n"), ".yaml")
+	require.EqualError(t, err, "YAML table needs at least one value column")
+
+	// rows shorter than the columns don't yield a value
+	table := &LookupTable{columns: []string{"team"}, rows: map[string][]string{"kube-system": {}}}
+	_, found := table.Lookup("kube-system", "")
+	require.False(t, found)
+	_, found = table.Lookup("kube-system", "team")
+	require.False(t, found)
+
+	file := filepath.Join(t.TempDir(), "teams.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("kube-system: {}\n"), 0o600))
+	config, err := NewConfig(bytes.NewBufferString(`lookup_tables:
+- name: teams
+  file: ` + file + `
+metrics:
+- name: lookup
+  labels:
+    team: lookup(teams, InvolvedObject.Namespace)
+`))
+	require.NoError(t, err)
+	require.EqualError(t, config.LoadLookupTables(nil, 0, nil), "failed to load lookup table 'teams': failed to parse lookup table 'teams': YAML table needs at least one value column")
+}
+
+func TestConfigErrorLookupTable(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: lookup
+  labels:
+    team: lookup(teams, InvolvedObject.Namespace)
+`)
+	_, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.EqualError(t, err, "configuration for metric 'lookup' invalid: Unknown lookup table 'teams'")
+}
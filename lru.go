@@ -0,0 +1,106 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache maps event UIDs to their last processed version and keeps at most
+// size entries. When it is full, adding an entry evicts the least recently
+// used one.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+type lruEntry struct {
+	key   string
+	value eventVersion
+}
+
+// eventVersion describes the last processed version of an event.
+type eventVersion struct {
+	ResourceVersion string
+	// occurrences of the event up to this version
+	Occurrences int
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// Get returns the value for key and marks it as recently used.
+func (c *lruCache) Get(key string) (eventVersion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, found := c.items[key]
+	if !found {
+		return eventVersion{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+// Add sets the value for key and marks it as recently used.
+func (c *lruCache) Add(key string, value eventVersion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, found := c.items[key]; found {
+		element.Value.(*lruEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, found := c.items[key]; found {
+		c.order.Remove(element)
+		delete(c.items, key)
+	}
+}
+
+// Entries returns all entries from the least to the most recently used one,
+// so adding them in order to an empty cache restores it.
+func (c *lruCache) Entries() []lruEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]lruEntry, 0, c.order.Len())
+	for element := c.order.Back(); element != nil; element = element.Prev() {
+		entries = append(entries, *element.Value.(*lruEntry))
+	}
+	return entries
+}
+
+func (c *lruCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
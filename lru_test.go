@@ -0,0 +1,73 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(2)
+	cache.Add("a", eventVersion{ResourceVersion: "1"})
+	cache.Add("b", eventVersion{ResourceVersion: "2"})
+	value, found := cache.Get("a")
+	require.True(t, found)
+	require.Equal(t, eventVersion{ResourceVersion: "1"}, value)
+
+	// b is the least recently used entry
+	cache.Add("c", eventVersion{ResourceVersion: "3"})
+	_, found = cache.Get("b")
+	require.False(t, found)
+	require.Equal(t, 2, cache.Len())
+
+	cache.Add("a", eventVersion{ResourceVersion: "4", Occurrences: 2})
+	value, _ = cache.Get("a")
+	require.Equal(t, eventVersion{ResourceVersion: "4", Occurrences: 2}, value)
+	cache.Remove("a")
+	_, found = cache.Get("a")
+	require.False(t, found)
+}
+
+func TestDuplicateEvents(t *testing.T) {
+	router := &EventRouter{processed: newLRUCache(10)}
+	var m dto.Metric
+	require.NoError(t, duplicateEventsCounter.Write(&m))
+	duplicates := m.GetCounter().GetValue()
+
+	event := &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1234", ResourceVersion: "1"}, Count: 3}
+	previous, duplicate := router.markProcessed(event)
+	require.Nil(t, previous)
+	require.False(t, duplicate)
+	_, duplicate = router.markProcessed(event)
+	require.True(t, duplicate)
+	event.ResourceVersion = "2"
+	event.Count = 5
+	previous, duplicate = router.markProcessed(event)
+	require.Equal(t, &eventVersion{ResourceVersion: "1", Occurrences: 3}, previous)
+	require.False(t, duplicate)
+
+	require.NoError(t, duplicateEventsCounter.Write(&m))
+	require.InDelta(t, duplicates+1, m.GetCounter().GetValue(), 0)
+
+	// deduplication is disabled without cache
+	previous, duplicate = (&EventRouter{}).markProcessed(event)
+	require.Nil(t, previous)
+	require.False(t, duplicate)
+}
@@ -38,6 +38,13 @@ var (
 	kubeconfigFile  string
 	kubeContext     string
 	discardInterval time.Duration
+	timeLayout      string
+	lookupReload    time.Duration
+	resyncPeriod    time.Duration
+	dedupSize       int
+	stateFile       string
+	stateInterval   time.Duration
+	startupPolicy   string
 )
 
 func init() {
@@ -46,6 +53,13 @@ func init() {
 	flag.StringVar(&metricsAddr, "listen-address", ":9102", "The address to listen on for HTTP requests.")
 	flag.StringVar(&kubeconfigFile, "kubeconfig", "", "Use explicit kubeconfig file")
 	flag.StringVar(&kubeContext, "context", "", "Use context")
+	flag.DurationVar(&lookupReload, "lookup-reload", time.Minute, "Interval for reloading lookup tables. Set to 0 to disable")
+	flag.DurationVar(&resyncPeriod, "resync", 30*time.Minute, "Interval in which the informer redelivers all events. Set to 0 to disable")
+	flag.IntVar(&dedupSize, "dedup-size", 10000, "Number of processed event versions remembered to skip duplicate deliveries. Set to 0 to disable")
+	flag.StringVar(&stateFile, "state-file", "", "File for persisting counters and processed events across restarts, e.g. on a persistent volume")
+	flag.DurationVar(&stateInterval, "state-interval", time.Minute, "Interval for saving the state file")
+	flag.StringVar(&startupPolicy, "startup-policy", StartupPolicyWindow, "Which events of the initial list on startup are counted: skip (none), window (those within the discard interval) or all")
+	flag.StringVar(&timeLayout, "time-layout", time.RFC3339, "Layout for timestamps used as label values, see https://pkg.go.dev/time#Layout")
 }
 
 func sigHandler() <-chan struct{} {
@@ -67,6 +81,16 @@ func main() {
 
 	flag.Parse()
 
+	switch startupPolicy {
+	case StartupPolicySkip, StartupPolicyWindow, StartupPolicyAll:
+	default:
+		glog.Fatalf("Unknown startup policy '%s'", startupPolicy)
+	}
+	// without processed event versions, the initial list would be counted again after a restart
+	if stateFile != "" && dedupSize <= 0 {
+		glog.Fatalf("-state-file needs -dedup-size greater than 0")
+	}
+
 	yamlFile, err := os.Open(configFile)
 	if err != nil {
 		glog.Fatalf("Failed to open file %s: %v", configFile, err)
@@ -88,7 +112,7 @@ func main() {
 		glog.Fatal("Could not create client set", err)
 	}
 
-	sharedInformers := informers.NewSharedInformerFactory(clientset, time.Minute*30)
+	sharedInformers := informers.NewSharedInformerFactory(clientset, resyncPeriod)
 	eventsInformer := sharedInformers.Core().V1().Events()
 
 	eventRouter, err := NewEventRouter(clientset, eventsInformer, config)
@@ -97,6 +121,17 @@ func main() {
 	}
 	stop := sigHandler()
 
+	if stateFile != "" {
+		if err := eventRouter.LoadState(stateFile); err != nil {
+			glog.Errorf("Could not load state, starting without: %v", err)
+		}
+		go eventRouter.CheckpointState(stateFile, stateInterval, stop)
+	}
+
+	if err := config.LoadLookupTables(clientset, lookupReload, stop); err != nil {
+		glog.Fatalf("Could not load lookup tables: %v", err)
+	}
+
 	go func() {
 		glog.Info("Starting prometheus metrics")
 		mux := http.NewServeMux()
@@ -118,6 +153,11 @@ func main() {
 	glog.Infof("Starting shared Informer(s)")
 	sharedInformers.Start(stop)
 	wg.Wait()
+	if stateFile != "" {
+		if err := eventRouter.SaveState(stateFile); err != nil {
+			glog.Errorf("Could not save state: %v", err)
+		}
+	}
 	glog.Warningf("Exiting main()")
 	os.Exit(1)
 }
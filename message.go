@@ -0,0 +1,182 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	MessageFieldsPrefix = "Message.fields."
+
+	MessageFormatJSON   = "json"
+	MessageFormatLogfmt = "logfmt"
+	MessageFormatKV     = "kv"
+)
+
+var (
+	// matches e.g. `error_code=42` or `reason="disk full"` anywhere in a message
+	messageKVRE = regexp.MustCompile(`([A-Za-z_][\w.-]*)=("(?:[^"\\]|\\.)*"|\S*)`)
+
+	messageParsers = map[string]MessageParser{
+		MessageFormatJSON:   parseJSONMessage,
+		MessageFormatLogfmt: parseLogfmtMessage,
+		MessageFormatKV:     parseKVMessage,
+	}
+)
+
+// MessageParser parses the message of an event into fields.
+type MessageParser = func(message string) (map[string]string, error)
+
+// MessageFields returns the fields of the event message parsed with the
+// message_format of the metric. The message is parsed at most once and
+// parse errors are counted per metric.
+func (ctx *LookupContext) MessageFields() (map[string]string, error) {
+	if ctx.metric == nil || ctx.metric.messageParser == nil {
+		return nil, errors.New("metric has no message_format")
+	}
+	if ctx.messageFields == nil && ctx.messageErr == nil {
+		ctx.messageFields, ctx.messageErr = ctx.metric.messageParser(ctx.Event.Message)
+		if ctx.messageErr != nil {
+			messageParseErrorsCounter.WithLabelValues(ctx.metric.Name).Inc()
+		}
+	}
+	return ctx.messageFields, ctx.messageErr
+}
+
+func newMessageFieldLookup(metric *MetricConfig, key string) (LookupFunc, error) {
+	if metric == nil || metric.messageParser == nil {
+		return nil, fmt.Errorf("Can't use key '%s' without message_format", key)
+	}
+	field := strings.TrimPrefix(key, MessageFieldsPrefix)
+	return func(ctx *LookupContext) (string, error) {
+		fields, err := ctx.MessageFields()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse message: %w", err)
+		}
+		value, found := fields[field]
+		if !found {
+			return "", fmt.Errorf("field '%s' not found in message", field)
+		}
+		return value, nil
+	}, nil
+}
+
+// parseJSONMessage parses a JSON object. Nested objects are flattened with
+// dots, e.g. `{"error": {"code": 42}}` yields the field `error.code`.
+func parseJSONMessage(message string) (map[string]string, error) {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &object); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	flattenJSON(fields, "", object)
+	return fields, nil
+}
+
+func flattenJSON(fields map[string]string, prefix string, object map[string]interface{}) {
+	for key, value := range object {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenJSON(fields, prefix+key+".", v)
+		case string:
+			fields[prefix+key] = v
+		case nil:
+			fields[prefix+key] = ""
+		case float64:
+			fields[prefix+key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			fields[prefix+key] = strconv.FormatBool(v)
+		default:
+			encoded, err := json.Marshal(v)
+			if err == nil {
+				fields[prefix+key] = string(encoded)
+			}
+		}
+	}
+}
+
+// parseLogfmtMessage parses a message consisting only of `key=value` pairs
+// and bare keys separated by whitespace. Values may be quoted.
+func parseLogfmtMessage(message string) (map[string]string, error) {
+	fields := make(map[string]string)
+	rest := strings.TrimSpace(message)
+	for rest != "" {
+		end := strings.IndexFunc(rest, func(r rune) bool { return r == '=' || unicode.IsSpace(r) })
+		if end == -1 {
+			end = len(rest)
+		}
+		key := rest[:end]
+		if key == "" || strings.ContainsRune(key, '"') {
+			return nil, fmt.Errorf("invalid key at '%s'", rest)
+		}
+		rest = rest[end:]
+
+		value := ""
+		if strings.HasPrefix(rest, "=") {
+			rest = rest[1:]
+			if strings.HasPrefix(rest, `"`) {
+				quoted, err := strconv.QuotedPrefix(rest)
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted value for key '%s'", key)
+				}
+				rest = rest[len(quoted):]
+				value, err = strconv.Unquote(quoted)
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted value for key '%s'", key)
+				}
+			} else {
+				end := strings.IndexFunc(rest, unicode.IsSpace)
+				if end == -1 {
+					end = len(rest)
+				}
+				value, rest = rest[:end], rest[end:]
+			}
+			if rest != "" && !unicode.IsSpace(rune(rest[0])) {
+				return nil, fmt.Errorf("missing whitespace after value of key '%s'", key)
+			}
+		}
+		fields[key] = value
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("message is empty")
+	}
+	return fields, nil
+}
+
+// parseKVMessage extracts `key=value` pairs from a free text message.
+func parseKVMessage(message string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, match := range messageKVRE.FindAllStringSubmatch(message, -1) {
+		value := match[2]
+		if strings.HasPrefix(value, `"`) {
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+		}
+		fields[match[1]] = value
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("message contains no key=value pairs")
+	}
+	return fields, nil
+}
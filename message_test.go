@@ -0,0 +1,85 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestParseMessage(t *testing.T) {
+	fields, err := parseJSONMessage(`{"msg": "attach failed", "error": {"code": 42, "retry": true}}`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"msg": "attach failed", "error.code": "42", "error.retry": "true"}, fields)
+
+	fields, err = parseLogfmtMessage(`level=error msg="attach failed: \"vol-1\"" error_code=42 fatal`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"level": "error", "msg": `attach failed: "vol-1"`, "error_code": "42", "fatal": ""}, fields)
+	_, err = parseLogfmtMessage(`"not logfmt" at all`)
+	require.Error(t, err)
+
+	fields, err = parseKVMessage(`Failed to attach volume=vol-1 to node, error_code=42 reason="disk full"`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"volume": "vol-1", "error_code": "42", "reason": "disk full"}, fields)
+	_, err = parseKVMessage(`Created container app`)
+	require.Error(t, err)
+}
+
+func TestMessageFormat(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: controller_errors
+  message_format: kv
+  event_matcher:
+  - key: Message.fields.error_code
+    expr: ^5
+  - key: Reason
+    expr: ReconcileError
+  labels:
+    code: Message.fields.error_code
+    component: '{{ .MessageFields.component }}'
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	testEvent := v1.Event{Reason: "ReconcileError", Message: "reconcile failed component=loadbalancer error_code=503"}
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+	require.Equal(t, []FilterMatch{
+		{Name: "controller_errors", Labels: map[string]string{"code": "503", "component": "loadbalancer"}, Value: 1},
+	}, matches)
+
+	parseErrors := counterValue(t, messageParseErrorsCounter, "controller_errors")
+	testEvent = v1.Event{Reason: "ReconcileError", Message: "reconcile failed"}
+	require.Empty(t, LogEvent(&testEvent, &EventRouter{Config: config}))
+	require.Equal(t, parseErrors+1, counterValue(t, messageParseErrorsCounter, "controller_errors"))
+
+	// messages of otherwise not matching events aren't parsed, whatever the order of the matchers
+	testEvent = v1.Event{Reason: "Pulled", Message: "Successfully pulled image"}
+	require.Empty(t, LogEvent(&testEvent, &EventRouter{Config: config}))
+	require.Equal(t, parseErrors+1, counterValue(t, messageParseErrorsCounter, "controller_errors"))
+}
+
+func TestConfigErrorMessageFields(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: fields
+  event_matcher:
+  - key: Message.fields.error_code
+    expr: .*
+`)
+	_, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.EqualError(t, err, "configuration for metric 'fields' invalid: Can't use key 'Message.fields.error_code' without message_format")
+}
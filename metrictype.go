@@ -0,0 +1,267 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	MetricTypeCounter   = "counter"
+	MetricTypeGauge     = "gauge"
+	MetricTypeHistogram = "histogram"
+	MetricTypeSummary   = "summary"
+)
+
+var (
+	// matches e.g. `duration(FirstTimestamp, LastTimestamp)`
+	durationValueRE = regexp.MustCompile(`^duration\(\s*([^,\s]+)\s*,\s*([^,\s]+)\s*\)$`)
+)
+
+// ValueFunc returns the value a match is observed with.
+type ValueFunc = func(ctx *LookupContext) (float64, error)
+
+// compileMetricType validates the type specific options of a metric and
+// creates the lookup for its value.
+func compileMetricType(metric *MetricConfig) error {
+	switch metric.Type {
+	case "":
+		metric.Type = MetricTypeCounter
+	case MetricTypeCounter, MetricTypeGauge, MetricTypeHistogram, MetricTypeSummary:
+	default:
+		return fmt.Errorf("configuration for metric '%s' invalid: Unknown type '%s'", metric.Name, metric.Type)
+	}
+
+	if metric.Buckets != nil && metric.Type != MetricTypeHistogram {
+		return fmt.Errorf("configuration for metric '%s' invalid: buckets can only be used with type histogram", metric.Name)
+	}
+	if metric.NativeHistogramBucketFactor != 0 && metric.Type != MetricTypeHistogram {
+		return fmt.Errorf("configuration for metric '%s' invalid: native_histogram_bucket_factor can only be used with type histogram", metric.Name)
+	}
+	if err := validateBuckets(metric); err != nil {
+		return err
+	}
+	if metric.Window < 0 {
+		return fmt.Errorf("configuration for metric '%s' invalid: window must not be negative", metric.Name)
+	}
+	if metric.Window > 0 && metric.Type != MetricTypeCounter {
+		return fmt.Errorf("configuration for metric '%s' invalid: window can only be used with type counter", metric.Name)
+	}
+	if metric.Objectives != nil && metric.Type != MetricTypeSummary {
+		return fmt.Errorf("configuration for metric '%s' invalid: objectives can only be used with type summary", metric.Name)
+	}
+
+	switch metric.Accounting {
+	case "":
+		metric.Accounting = AccountingNotifications
+	case AccountingNotifications:
+	case AccountingOccurrences:
+		if metric.Type != MetricTypeCounter || metric.Value != "" {
+			return fmt.Errorf("configuration for metric '%s' invalid: accounting %s can only be used for counters without value", metric.Name, metric.Accounting)
+		}
+		metric.valueLookup = occurrencesLookup
+		return nil
+	default:
+		return fmt.Errorf("configuration for metric '%s' invalid: Unknown accounting '%s'", metric.Name, metric.Accounting)
+	}
+
+	if metric.Value == "" {
+		if metric.Type != MetricTypeCounter {
+			return fmt.Errorf("configuration for metric '%s' invalid: No value for metric of type %s", metric.Name, metric.Type)
+		}
+		return nil
+	}
+	var err error
+	metric.valueLookup, err = newValueLookup(metric, metric.Value)
+	return err
+}
+
+// validateBuckets validates the bucket options of a histogram.
+func validateBuckets(metric *MetricConfig) error {
+	if metric.NativeHistogramBucketFactor != 0 && metric.NativeHistogramBucketFactor <= 1 {
+		return fmt.Errorf("configuration for metric '%s' invalid: native_histogram_bucket_factor has to be greater than 1", metric.Name)
+	}
+	for i := 1; i < len(metric.Buckets); i++ {
+		if metric.Buckets[i] <= metric.Buckets[i-1] {
+			return fmt.Errorf("configuration for metric '%s' invalid: buckets have to be in increasing order", metric.Name)
+		}
+	}
+	return nil
+}
+
+// newValueLookup creates the lookup for the value of a metric. It is either a
+// duration between two timestamps of the event, given as
+// `duration(<start>, <end>)`, or any label source yielding a number.
+func newValueLookup(metric *MetricConfig, valueSpec string) (ValueFunc, error) {
+	if match := durationValueRE.FindStringSubmatch(valueSpec); match != nil {
+		start, end := match[1], match[2]
+		return func(ctx *LookupContext) (float64, error) {
+			startTime, err := getTimeFromEvent(ctx, start)
+			if err != nil {
+				return 0, err
+			}
+			endTime, err := getTimeFromEvent(ctx, end)
+			if err != nil {
+				return 0, err
+			}
+			return endTime.Sub(startTime).Seconds(), nil
+		}, nil
+	}
+
+	lookup, err := newLabelLookup(metric, valueSpec)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx *LookupContext) (float64, error) {
+		value, err := lookup(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return ParseValue(value)
+	}, nil
+}
+
+// ParseValue parses the value of a metric. Besides plain numbers, resource
+// quantities (e.g. `500Mi`) and durations (e.g. `2m30s`, in seconds) are
+// accepted.
+func ParseValue(value string) (float64, error) {
+	f, err := parseNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	// a single NaN or infinite value would spoil a series for good
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("value '%s' is not a finite number", value)
+	}
+	return f, nil
+}
+
+func parseNumber(value string) (float64, error) {
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, nil
+	}
+	// quantities first, so `500m` is half a CPU rather than 500 minutes
+	if q, err := resource.ParseQuantity(value); err == nil {
+		return q.AsApproximateFloat64(), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d.Seconds(), nil
+	}
+	return 0, fmt.Errorf("value '%s' is not a number", value)
+}
+
+func getTimeFromEvent(ctx *LookupContext, path string) (time.Time, error) {
+	value, err := GetFieldFromStruct(ctx.Event, path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var t time.Time
+	switch v := value.(type) {
+	case metav1.Time:
+		t = v.Time
+	case metav1.MicroTime:
+		t = v.Time
+	case time.Time:
+		t = v
+	default:
+		return time.Time{}, fmt.Errorf("value of type %T at %s is not a timestamp", value, path)
+	}
+	if t.IsZero() {
+		return time.Time{}, fmt.Errorf("timestamp %s is not set", path)
+	}
+	return t, nil
+}
+
+// newMetricCollector creates the collector for a metric according to its type.
+func newMetricCollector(metric *MetricConfig, labels []string) prometheus.Collector {
+	help := "Kubernetes Eventexporter Metric " + metric.Name
+	if metric.Condition != nil {
+		return newConditionVec(metric.Name, help, labels, metric.Condition.Timeout)
+	}
+	if metric.Latency != nil {
+		return newLatencyVec(histogramOpts(metric, help), labels, metric.Latency.Timeout)
+	}
+	if metric.Distinct != nil {
+		return newDistinctVec(metric.Name, help, labels, metric.Window, metric.Distinct.MaxExact)
+	}
+	if metric.Window > 0 {
+		return newWindowVec(metric.Name, help, labels, metric.Window)
+	}
+	switch metric.Type {
+	case MetricTypeGauge:
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: metric.Name, Help: help}, labels)
+	case MetricTypeHistogram:
+		return prometheus.NewHistogramVec(histogramOpts(metric, help), labels)
+	case MetricTypeSummary:
+		return prometheus.NewSummaryVec(prometheus.SummaryOpts{Name: metric.Name, Help: help, Objectives: metric.Objectives}, labels)
+	}
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: metric.Name, Help: help}, labels)
+}
+
+func histogramOpts(metric *MetricConfig, help string) prometheus.HistogramOpts {
+	opts := prometheus.HistogramOpts{Name: metric.Name, Help: help, Buckets: metric.Buckets}
+	if metric.NativeHistogramBucketFactor != 0 {
+		opts.NativeHistogramBucketFactor = metric.NativeHistogramBucketFactor
+		opts.NativeHistogramMaxBucketNumber = 160
+		opts.NativeHistogramMinResetDuration = time.Hour
+	}
+	return opts
+}
+
+// observeMetric records the value of a match in the collector of a metric.
+func observeMetric(collector prometheus.Collector, labels map[string]string, value float64) error {
+	switch vec := collector.(type) {
+	case *prometheus.CounterVec:
+		if value < 0 {
+			return fmt.Errorf("counter can't be increased by negative value %g", value)
+		}
+		counter, err := vec.GetMetricWith(labels)
+		if err != nil {
+			return err
+		}
+		counter.Add(value)
+	case *prometheus.GaugeVec:
+		gauge, err := vec.GetMetricWith(labels)
+		if err != nil {
+			return err
+		}
+		gauge.Set(value)
+	case *prometheus.HistogramVec:
+		observer, err := vec.GetMetricWith(labels)
+		if err != nil {
+			return err
+		}
+		observer.Observe(value)
+	case *prometheus.SummaryVec:
+		observer, err := vec.GetMetricWith(labels)
+		if err != nil {
+			return err
+		}
+		observer.Observe(value)
+	case *windowVec:
+		return vec.Add(labels, value)
+	default:
+		return fmt.Errorf("unsupported collector %T", collector)
+	}
+	return nil
+}
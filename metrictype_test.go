@@ -0,0 +1,147 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMetricValue(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: backoff_repetitions
+  type: histogram
+  buckets: [1, 5, 10, 50]
+  value: Count
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+- name: backoff_duration_seconds
+  type: summary
+  value: duration(FirstTimestamp, LastTimestamp)
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+- name: restarts
+  type: gauge
+  value: Message[1]
+  event_matcher:
+  - key: Message
+    expr: restarted (\d+) times
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	now := time.Now()
+	testEvent := v1.Event{
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container, restarted 7 times",
+		Count:          12,
+		FirstTimestamp: metav1.NewTime(now.Add(-90 * time.Second)),
+		LastTimestamp:  metav1.NewTime(now),
+	}
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+	require.Equal(t, []FilterMatch{
+		{Name: "backoff_repetitions", Labels: map[string]string{}, Value: 12},
+		{Name: "backoff_duration_seconds", Labels: map[string]string{}, Value: 90},
+		{Name: "restarts", Labels: map[string]string{}, Value: 7},
+	}, matches)
+
+	// events without FirstTimestamp can't be observed by duration
+	testEvent.FirstTimestamp = metav1.Time{}
+	require.Len(t, LogEvent(&testEvent, &EventRouter{Config: config}), 2)
+}
+
+func TestParseValue(t *testing.T) {
+	for value, expected := range map[string]float64{
+		"42":    42,
+		"-1.5":  -1.5,
+		"2m30s": 150,
+		"500Mi": 500 * 1024 * 1024,
+		"250m":  0.25,
+		"90s":   90,
+	} {
+		actual, err := ParseValue(value)
+		require.NoError(t, err, value)
+		require.InDelta(t, expected, actual, 1e-9, value)
+	}
+	_, err := ParseValue("many")
+	require.EqualError(t, err, "value 'many' is not a number")
+	for _, value := range []string{"NaN", "Inf", "+Inf", "-inf", "1e400"} {
+		_, err = ParseValue(value)
+		require.Error(t, err, value)
+	}
+	_, err = ParseValue("NaN")
+	require.EqualError(t, err, "value 'NaN' is not a finite number")
+}
+
+func TestObserveMetric(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: counter
+- name: gauge
+  type: gauge
+  value: Count
+- name: histogram
+  type: histogram
+  buckets: [1, 10]
+  value: Count
+`))
+	require.NoError(t, err)
+	labels := []string{"reason"}
+	counter := newMetricCollector(&config.Metrics[0], labels)
+	gauge := newMetricCollector(&config.Metrics[1], labels)
+	histogram := newMetricCollector(&config.Metrics[2], labels)
+
+	for _, value := range []float64{3, 20} {
+		require.NoError(t, observeMetric(counter, map[string]string{"reason": "BackOff"}, value))
+		require.NoError(t, observeMetric(gauge, map[string]string{"reason": "BackOff"}, value))
+		require.NoError(t, observeMetric(histogram, map[string]string{"reason": "BackOff"}, value))
+	}
+	require.Error(t, observeMetric(counter, map[string]string{"reason": "BackOff"}, -1))
+
+	require.InDelta(t, 23, counterValue(t, counter.(*prometheus.CounterVec), "BackOff"), 0)
+
+	var m dto.Metric
+	require.NoError(t, gauge.(*prometheus.GaugeVec).WithLabelValues("BackOff").Write(&m))
+	require.InDelta(t, 20, m.GetGauge().GetValue(), 0)
+
+	observer, err := histogram.(*prometheus.HistogramVec).GetMetricWithLabelValues("BackOff")
+	require.NoError(t, err)
+	require.NoError(t, observer.(prometheus.Metric).Write(&m))
+	require.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	require.Equal(t, uint64(0), m.GetHistogram().GetBucket()[0].GetCumulativeCount())
+	require.Equal(t, uint64(1), m.GetHistogram().GetBucket()[1].GetCumulativeCount())
+}
+
+func TestConfigErrorMetricType(t *testing.T) {
+	for testConfig, expected := range map[string]string{
+		"metrics:\n- name: m\n  type: meter\n":                                                          "configuration for metric 'm' invalid: Unknown type 'meter'",
+		"metrics:\n- name: m\n  type: gauge\n":                                                          "configuration for metric 'm' invalid: No value for metric of type gauge",
+		"metrics:\n- name: m\n  buckets: [1, 2]\n":                                                      "configuration for metric 'm' invalid: buckets can only be used with type histogram",
+		"metrics:\n- name: m\n  type: histogram\n  value: Count\n  buckets: [2, 1]\n":                   "configuration for metric 'm' invalid: buckets have to be in increasing order",
+		"metrics:\n- name: m\n  type: histogram\n  value: Count\n  native_histogram_bucket_factor: 1\n": "configuration for metric 'm' invalid: native_histogram_bucket_factor has to be greater than 1",
+		"metrics:\n- name: m\n  type: gauge\n  value: Count\n  accounting: occurrences\n":               "configuration for metric 'm' invalid: accounting occurrences can only be used for counters without value",
+	} {
+		_, err := NewConfig(bytes.NewBufferString(testConfig))
+		require.EqualError(t, err, expected)
+	}
+}
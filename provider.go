@@ -0,0 +1,154 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ProviderVirtualTypePrefix = "Provider."
+)
+
+var (
+	// AWS zones are the region with a trailing letter, e.g. `eu-central-1a`
+	awsZoneRE = regexp.MustCompile(`^(.*\d)[a-z]+$`)
+	// GCE zones are the region with a trailing suffix, e.g. `europe-west1-b`
+	gceZoneRE = regexp.MustCompile(`^(.*)-[a-z]$`)
+	// matches e.g. `/subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.Compute/virtualMachineScaleSets/<set>/virtualMachines/<index>`
+	azureResourceRE = regexp.MustCompile(`(?i)^/subscriptions/([^/]+)/resourceGroups/[^/]+/providers/Microsoft\.Compute/(?:virtualMachineScaleSets/([^/]+)/)?virtualMachines/([^/]+)$`)
+)
+
+// ProviderInfo describes the cloud provider instance behind the node an
+// event refers to. Its fields are exposed as `Provider.<Field>` labels.
+type ProviderInfo struct {
+	// e.g. aws, gce, azure or openstack
+	Name string
+	// project of GCE, subscription of Azure
+	Account    string
+	Region     string
+	Zone       string
+	InstanceID string
+	// Spec.ProviderID of the node as is
+	ID string
+}
+
+// providerInfo returns the provider instance behind the node of the event. It
+// is looked up once per event and shared by all metrics.
+func (ctx *LookupContext) providerInfo() (*ProviderInfo, error) {
+	if ctx.objects == nil {
+		ctx.objects = &eventObjects{}
+	}
+	if !ctx.objects.providerFetched {
+		ctx.objects.provider, ctx.objects.providerErr = getProviderInfoForEvent(ctx)
+		ctx.objects.providerFetched = true
+	}
+	return ctx.objects.provider, ctx.objects.providerErr
+}
+
+func getProviderInfoForEvent(ctx *LookupContext) (*ProviderInfo, error) {
+	nodeName, err := getNodeNameForEvent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	node, err := eventRouter.kubeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	info := ParseProviderID(node.Spec.ProviderID)
+	// region and zone are not part of all formats, but usually set as well-known labels
+	if info.Region == "" {
+		info.Region = node.Labels[v1.LabelTopologyRegion]
+	}
+	if info.Zone == "" {
+		info.Zone = node.Labels[v1.LabelTopologyZone]
+	}
+	return info, nil
+}
+
+func getNodeNameForEvent(ctx *LookupContext) (string, error) {
+	event := ctx.Event
+	switch event.InvolvedObject.Kind {
+	case "Node":
+		return event.InvolvedObject.Name, nil
+	case "Pod":
+		pod, err := ctx.Object()
+		if err != nil {
+			return "", err
+		}
+		if pod.Spec.NodeName == "" {
+			return "", errors.New("pod is not scheduled to a node")
+		}
+		return pod.Spec.NodeName, nil
+	}
+	if event.Source.Host != "" {
+		return event.Source.Host, nil
+	}
+	return "", errors.New("event does not refer to a node")
+}
+
+// ParseProviderID parses the Spec.ProviderID of a node for the formats of
+// common cloud providers. Unknown formats yield the raw value as InstanceID.
+func ParseProviderID(providerID string) *ProviderInfo {
+	info := &ProviderInfo{ID: providerID, InstanceID: providerID}
+	name, rest, found := strings.Cut(providerID, "://")
+	if !found {
+		return info
+	}
+	info.Name = name
+	parts := strings.Split(rest, "/")
+
+	switch name {
+	case "aws":
+		// aws:///<zone>/<instance>
+		if len(parts) == 3 && parts[0] == "" {
+			info.Zone, info.InstanceID = parts[1], parts[2]
+			if match := awsZoneRE.FindStringSubmatch(info.Zone); match != nil {
+				info.Region = match[1]
+			}
+		}
+	case "gce":
+		// gce://<project>/<zone>/<instance>
+		if len(parts) == 3 {
+			info.Account, info.Zone, info.InstanceID = parts[0], parts[1], parts[2]
+			if match := gceZoneRE.FindStringSubmatch(info.Zone); match != nil {
+				info.Region = match[1]
+			}
+		}
+	case "azure":
+		// azure:///subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.Compute/virtualMachines/<name>
+		if match := azureResourceRE.FindStringSubmatch(rest); match != nil {
+			info.Account, info.InstanceID = match[1], match[3]
+			if match[2] != "" {
+				// instances of scale sets are named <set>_<index>
+				info.InstanceID = match[2] + "_" + match[3]
+			}
+		}
+	case "openstack":
+		// openstack:///<instance> or openstack://<region>/<instance>
+		if len(parts) == 2 {
+			info.Region, info.InstanceID = parts[0], parts[1]
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseProviderID(t *testing.T) {
+	for providerID, expected := range map[string]ProviderInfo{
+		"aws:///eu-central-1a/i-0123456789abcdef0": {Name: "aws", Region: "eu-central-1", Zone: "eu-central-1a", InstanceID: "i-0123456789abcdef0"},
+		"gce://my-project/europe-west1-b/node-1":   {Name: "gce", Account: "my-project", Region: "europe-west1", Zone: "europe-west1-b", InstanceID: "node-1"},
+		"azure:///subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm-1": {
+			Name: "azure", Account: "sub-1", InstanceID: "vm-1",
+		},
+		"azure:///subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/pool/virtualMachines/3": {
+			Name: "azure", Account: "sub-1", InstanceID: "pool_3",
+		},
+		"openstack:///8c3a7d42-6e4f-4a8e-9c5d-1f2b3c4d5e6f":        {Name: "openstack", InstanceID: "8c3a7d42-6e4f-4a8e-9c5d-1f2b3c4d5e6f"},
+		"openstack://eu-de-1/8c3a7d42-6e4f-4a8e-9c5d-1f2b3c4d5e6f": {Name: "openstack", Region: "eu-de-1", InstanceID: "8c3a7d42-6e4f-4a8e-9c5d-1f2b3c4d5e6f"},
+		"kind://docker/kind/kind-control-plane":                    {Name: "kind", InstanceID: "kind://docker/kind/kind-control-plane"},
+		"some-instance":                                            {InstanceID: "some-instance"},
+	} {
+		expected.ID = providerID
+		require.Equal(t, &expected, ParseProviderID(providerID), providerID)
+	}
+}
+
+func TestProviderReference(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: provider
+  event_matcher:
+  - key: Reason
+    expr: OOMKilling
+  labels:
+    provider: Provider.Name
+    region: Provider.Region
+    zone: Provider.Zone
+    instance: Provider.InstanceID
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{
+			v1.LabelTopologyRegion: "eu-de-1",
+			v1.LabelTopologyZone:   "eu-de-1a",
+		}},
+		Spec: v1.NodeSpec{ProviderID: "openstack:///8c3a7d42"},
+	}
+	fakeClient := fake.NewSimpleClientset(pod, node)
+
+	// the pod and node are looked up once for all labels
+	for event, requests := range map[*v1.Event]int{
+		{InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}, Reason: "OOMKilling"}: 2,
+		{InvolvedObject: v1.ObjectReference{Kind: "Node", Name: node.Name}, Reason: "OOMKilling"}:                         1,
+	} {
+		fakeClient.ClearActions()
+		matches := LogEvent(event, &EventRouter{Config: config, kubeClient: fakeClient})
+		require.Equal(t, []FilterMatch{
+			{Name: "provider", Labels: map[string]string{"provider": "openstack", "region": "eu-de-1", "zone": "eu-de-1a", "instance": "8c3a7d42"}, Value: 1},
+		}, matches)
+		require.Len(t, fakeClient.Actions(), requests)
+	}
+}
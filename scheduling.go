@@ -0,0 +1,150 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SchedulingVirtualTypePrefix = "Scheduling."
+)
+
+var (
+	// matches e.g. `0/12 nodes are available: 3 Insufficient cpu, 9 node(s) had untolerated taint {...}.`
+	schedulingMessageRE = regexp.MustCompile(`^(\d+)/(\d+) nodes are available(?::\s*(.*))?$`)
+	schedulingReasonRE  = regexp.MustCompile(`^(\d+) (?:node\(s\) )?(.+)$`)
+	// per-reason fields, a rule using one of them yields one series per reason
+	schedulingReasonFields = map[string]bool{"Reason": true, "Nodes": true}
+)
+
+// SchedulingInfo is the parsed form of a FailedScheduling message. Its fields
+// are exposed as `Scheduling.<Field>` keys.
+type SchedulingInfo struct {
+	AvailableNodes string
+	TotalNodes     string
+	// all reasons, sorted and comma separated
+	Reasons string
+	// reason which rules out the most nodes
+	TopReason string
+
+	reasons []SchedulingReason
+}
+
+// SchedulingReason is one entry of the reason list of a FailedScheduling
+// message with the number of nodes it rules out.
+type SchedulingReason struct {
+	Reason string
+	Nodes  string
+}
+
+func ParseSchedulingMessage(message string) (*SchedulingInfo, error) {
+	// the scheduler appends the result of the preemption attempt as another sentence
+	message, _, _ = strings.Cut(message, ". preemption:")
+	match := schedulingMessageRE.FindStringSubmatch(strings.TrimSuffix(strings.TrimSpace(message), "."))
+	if match == nil {
+		return nil, fmt.Errorf("message '%s' is not a scheduling failure", message)
+	}
+	info := &SchedulingInfo{AvailableNodes: match[1], TotalNodes: match[2]}
+
+	topNodes := -1
+	var names []string
+	for _, item := range splitOutsideBraces(match[3]) {
+		reasonMatch := schedulingReasonRE.FindStringSubmatch(item)
+		if reasonMatch == nil {
+			return nil, fmt.Errorf("failed to parse scheduling reason '%s'", item)
+		}
+		nodes, err := strconv.Atoi(reasonMatch[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse node count of scheduling reason '%s': %w", item, err)
+		}
+		if nodes > topNodes {
+			topNodes = nodes
+			info.TopReason = reasonMatch[2]
+		}
+		info.reasons = append(info.reasons, SchedulingReason{Reason: reasonMatch[2], Nodes: reasonMatch[1]})
+		names = append(names, reasonMatch[2])
+	}
+	sort.Strings(names)
+	info.Reasons = strings.Join(names, ",")
+
+	return info, nil
+}
+
+// schedulingReasonsLookup returns the reasons of a FailedScheduling message as
+// list for expanding a metric.
+func schedulingReasonsLookup(ctx *LookupContext) ([]interface{}, error) {
+	info, err := ParseSchedulingMessage(ctx.Event.Message)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, len(info.reasons))
+	for i, reason := range info.reasons {
+		items[i] = reason
+	}
+	return items, nil
+}
+
+func isSchedulingReasonField(key string) bool {
+	return strings.HasPrefix(key, SchedulingVirtualTypePrefix) && schedulingReasonFields[strings.TrimPrefix(key, SchedulingVirtualTypePrefix)]
+}
+
+// newSchedulingReasonValues returns a per-reason field of all reasons of a
+// FailedScheduling message for matching against any of them.
+func newSchedulingReasonValues(key string) ValuesFunc {
+	field := strings.TrimPrefix(key, SchedulingVirtualTypePrefix)
+	return func(ctx *LookupContext) ([]string, error) {
+		info, err := ParseSchedulingMessage(ctx.Event.Message)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]string, len(info.reasons))
+		for i, reason := range info.reasons {
+			values[i], err = GetValueFromStruct(reason, field)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+}
+
+// splitOutsideBraces splits a comma separated list, ignoring commas within
+// braces as they appear in taint and affinity descriptions.
+func splitOutsideBraces(list string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, c := range list {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(list[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestParseSchedulingMessage(t *testing.T) {
+	info, err := ParseSchedulingMessage("0/12 nodes are available: 3 Insufficient cpu, 9 node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }, 1 node(s) didn't match Pod's node affinity/selector. preemption: 0/12 nodes are available: 12 Preemption is not helpful for scheduling.")
+	require.NoError(t, err)
+	require.Equal(t, &SchedulingInfo{
+		AvailableNodes: "0",
+		TotalNodes:     "12",
+		Reasons:        "Insufficient cpu,didn't match Pod's node affinity/selector,had untolerated taint {node-role.kubernetes.io/control-plane: }",
+		TopReason:      "had untolerated taint {node-role.kubernetes.io/control-plane: }",
+		reasons: []SchedulingReason{
+			{Reason: "Insufficient cpu", Nodes: "3"},
+			{Reason: "had untolerated taint {node-role.kubernetes.io/control-plane: }", Nodes: "9"},
+			{Reason: "didn't match Pod's node affinity/selector", Nodes: "1"},
+		},
+	}, info)
+
+	_, err = ParseSchedulingMessage("Successfully assigned default/test-pod to node-1")
+	require.Error(t, err)
+}
+
+func TestSchedulingSeriesPerReason(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: unschedulable
+  event_matcher:
+  - key: Reason
+    expr: FailedScheduling
+  - key: Scheduling.TotalNodes
+    expr: .*
+  labels:
+    reason: Scheduling.Reason
+    nodes: Scheduling.Nodes
+    total: Scheduling.TotalNodes
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	testEvent := v1.Event{
+		Reason:  "FailedScheduling",
+		Message: "0/4 nodes are available: 1 Insufficient memory, 3 Insufficient cpu.",
+	}
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+
+	require.Equal(t, []FilterMatch{
+		{Name: "unschedulable", Labels: map[string]string{"reason": "Insufficient memory", "nodes": "1", "total": "4"}, Value: 1},
+		{Name: "unschedulable", Labels: map[string]string{"reason": "Insufficient cpu", "nodes": "3", "total": "4"}, Value: 1},
+	}, matches)
+}
+
+func TestSchedulingReasonMatcher(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: insufficient_resources
+  event_matcher:
+  - key: Reason
+    expr: FailedScheduling
+  - key: Scheduling.Reason
+    expr: ^Insufficient (.*)$
+  labels:
+    resource: Scheduling.Reason[1]
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	testEvent := v1.Event{
+		Reason:  "FailedScheduling",
+		Message: "0/4 nodes are available: 3 node(s) had untolerated taint {dedicated: gpu}, 1 Insufficient memory.",
+	}
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+	require.Equal(t, []FilterMatch{
+		{Name: "insufficient_resources", Labels: map[string]string{"resource": "memory"}, Value: 1},
+	}, matches)
+
+	testEvent.Message = "0/4 nodes are available: 4 node(s) had untolerated taint {dedicated: gpu}."
+	matches = LogEvent(&testEvent, &EventRouter{Config: config})
+	require.Empty(t, matches)
+
+	// per-reason fields have no single value outside of matchers and labels
+	_, err = NewConfig(bytes.NewBuffer([]byte(`metrics:
+- name: unschedulable
+  event_matcher:
+  - key: Reason
+    expr: FailedScheduling
+  expand:
+    source: Scheduling.Reason
+    expr: \w+
+  labels:
+    word: Item[0]
+`)))
+	require.EqualError(t, err, "configuration for metric 'unschedulable' invalid: Can't use per-reason field 'Scheduling.Reason' as a single value, use 'Scheduling.Reasons' or 'Scheduling.TopReason' instead")
+}
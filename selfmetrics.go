@@ -0,0 +1,39 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics about the eventexporter itself, as opposed to the configured metrics.
+var (
+	droppedMatchesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eventexporter_dropped_matches_total",
+		Help: "Matches which were dropped because a label could not be looked up",
+	}, []string{"metric", "label"})
+	messageParseErrorsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eventexporter_message_parse_errors_total",
+		Help: "Event messages which could not be parsed with the message_format of a metric",
+	}, []string{"metric"})
+	duplicateEventsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "eventexporter_duplicate_events_total",
+		Help: "Deliveries of already processed event versions, e.g. by informer resyncs, which were skipped",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(droppedMatchesCounter, messageParseErrorsCounter, duplicateEventsCounter)
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	SeverityKey = "Severity"
+	CategoryKey = "Category"
+
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+
+	// category of events which are not classified by a severity rule
+	defaultCategory = "other"
+)
+
+// SeverityRule assigns a severity and a category to the events matched by
+// its event matchers. Rules are evaluated in order and the first match wins.
+type SeverityRule struct {
+	Severity     string         `yaml:"severity"`
+	Category     string         `yaml:"category"`
+	EventMatcher []EventMatcher `yaml:"event_matcher"`
+	matchers     *matcherSet
+}
+
+// Classification is the result of the severity rules for an event. Its fields
+// are exposed as `Severity` and `Category` keys.
+type Classification struct {
+	Severity string
+	Category string
+}
+
+func (r *SeverityRule) compile() error {
+	switch r.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("Unknown severity '%s'", r.Severity)
+	}
+	if r.Severity == "" && r.Category == "" {
+		return errors.New("Neither severity nor category given")
+	}
+	for _, matcher := range r.EventMatcher {
+		if matcher.Key == SeverityKey || matcher.Key == CategoryKey {
+			return fmt.Errorf("Can't use key '%s' in a severity rule", matcher.Key)
+		}
+	}
+
+	var err error
+	r.matchers, err = newMatcherSet(nil, r.EventMatcher)
+	return err
+}
+
+// Classify evaluates the severity rules for an event. Events which are not
+// matched by a rule, or for which the rule gives no severity, get a severity
+// derived from their type.
+func (c *Config) Classify(event *v1.Event) *Classification {
+	classification := &Classification{Category: defaultCategory}
+	for i := range c.SeverityRules {
+		rule := &c.SeverityRules[i]
+		ctx := &LookupContext{Event: event, Matches: make(map[string][]string, len(rule.EventMatcher))}
+		if !rule.matchers.Match(ctx) {
+			continue
+		}
+		classification.Severity = rule.Severity
+		if rule.Category != "" {
+			classification.Category = rule.Category
+		}
+		break
+	}
+
+	if classification.Severity == "" {
+		classification.Severity = SeverityInfo
+		if event.Type == v1.EventTypeWarning {
+			classification.Severity = SeverityWarning
+		}
+	}
+	return classification
+}
+
+func newClassificationLookup(key string) LookupFunc {
+	return func(ctx *LookupContext) (string, error) {
+		if ctx.Classification == nil {
+			return "", fmt.Errorf("key '%s' is not available in severity rules", key)
+		}
+		if key == SeverityKey {
+			return ctx.Classification.Severity, nil
+		}
+		return ctx.Classification.Category, nil
+	}
+}
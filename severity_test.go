@@ -0,0 +1,89 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestSeverityRules(t *testing.T) {
+	testConfig := []byte(`severity_rules:
+- severity: critical
+  category: storage
+  event_matcher:
+  - key: Reason
+    expr: ^(FailedMount|FailedAttachVolume)$
+- category: scheduling
+  event_matcher:
+  - key: Source.Component
+    expr: scheduler
+- severity: info
+  event_matcher:
+  - key: Message
+    expr: "probe failed.*statuscode: 404"
+metrics:
+- name: kube_events_total
+  event_matcher:
+  - key: Severity
+    expr: warning|critical
+  labels:
+    severity: Severity
+    category: Category
+    reason: Reason
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	for _, tc := range []struct {
+		event    v1.Event
+		expected []FilterMatch
+	}{
+		{
+			event:    v1.Event{Type: v1.EventTypeWarning, Reason: "FailedMount"},
+			expected: []FilterMatch{{Name: "kube_events_total", Labels: map[string]string{"severity": "critical", "category": "storage", "reason": "FailedMount"}, Value: 1}},
+		},
+		{
+			event:    v1.Event{Type: v1.EventTypeWarning, Reason: "FailedScheduling", Source: v1.EventSource{Component: "default-scheduler"}},
+			expected: []FilterMatch{{Name: "kube_events_total", Labels: map[string]string{"severity": "warning", "category": "scheduling", "reason": "FailedScheduling"}, Value: 1}},
+		},
+		{
+			event:    v1.Event{Type: v1.EventTypeWarning, Reason: "BackOff"},
+			expected: []FilterMatch{{Name: "kube_events_total", Labels: map[string]string{"severity": "warning", "category": "other", "reason": "BackOff"}, Value: 1}},
+		},
+		{
+			event: v1.Event{Type: v1.EventTypeWarning, Reason: "Unhealthy", Message: "Liveness probe failed: HTTP probe failed with statuscode: 404"},
+		},
+		{
+			event: v1.Event{Type: v1.EventTypeNormal, Reason: "Pulled"},
+		},
+	} {
+		require.Equal(t, tc.expected, LogEvent(&tc.event, &EventRouter{Config: config}), tc.event.Reason)
+	}
+}
+
+func TestConfigErrorSeverityRules(t *testing.T) {
+	for testConfig, expected := range map[string]string{
+		"severity_rules:\n- severity: fatal\n":                                             "configuration for severity rule 1 invalid: Unknown severity 'fatal'",
+		"severity_rules:\n- category: storage\n  event_matcher:\n  - key: Category\n":      "configuration for severity rule 1 invalid: Can't use key 'Category' in a severity rule",
+		"severity_rules:\n- severity: info\n  event_matcher:\n  - key: Message.fields.x\n": "configuration for severity rule 1 invalid: Can't use key 'Message.fields.x' without message_format",
+	} {
+		_, err := NewConfig(bytes.NewBufferString(testConfig))
+		require.EqualError(t, err, expected)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// routerState is the checkpoint of an EventRouter which is persisted in the
+// state file, so counters continue and processed events are skipped after a
+// restart.
+type routerState struct {
+	// series of counters and gauges by metric name
+	Metrics map[string][]seriesState `json:"metrics"`
+	// processed event versions, least recently used first
+	Processed []processedEvent `json:"processed"`
+}
+
+type seriesState struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+type processedEvent struct {
+	UID             string `json:"uid"`
+	ResourceVersion string `json:"resourceVersion"`
+	Occurrences     int    `json:"occurrences"`
+}
+
+// SaveState writes the current state to path. The file is replaced
+// atomically, so it is never left half written.
+func (er *EventRouter) SaveState(path string) error {
+	state := routerState{Metrics: make(map[string][]seriesState, len(kubernetesEventMetricVec))}
+	for name, collector := range kubernetesEventMetricVec {
+		if series := collectSeries(collector); len(series) > 0 {
+			state.Metrics[name] = series
+		}
+	}
+	if er.processed != nil {
+		for _, entry := range er.processed.Entries() {
+			state.Processed = append(state.Processed, processedEvent{UID: entry.key, ResourceVersion: entry.value.ResourceVersion, Occurrences: entry.value.Occurrences})
+		}
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if err := json.NewEncoder(file).Encode(state); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// LoadState restores the state written by SaveState. A missing state file is
+// not an error, as there is none before the first checkpoint. Series of
+// metrics which are no longer configured or whose labels changed are dropped.
+func (er *EventRouter) LoadState(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+	defer file.Close()
+	var state routerState
+	if err := json.NewDecoder(file).Decode(&state); err != nil {
+		return fmt.Errorf("failed to read state from %s: %w", path, err)
+	}
+
+	for name, series := range state.Metrics {
+		collector, found := kubernetesEventMetricVec[name]
+		if !found {
+			glog.Warningf("Dropping state of metric '%s' which is not configured", name)
+			continue
+		}
+		for _, s := range series {
+			if err := restoreSeries(collector, s); err != nil {
+				glog.Warningf("Dropping state of metric '%s': %v", name, err)
+			}
+		}
+	}
+	if er.processed != nil {
+		for _, event := range state.Processed {
+			er.processed.Add(event.UID, eventVersion{ResourceVersion: event.ResourceVersion, Occurrences: event.Occurrences})
+		}
+	}
+	return nil
+}
+
+// CheckpointState saves the state to path in the given interval until stopCh
+// is closed.
+func (er *EventRouter) CheckpointState(path string, interval time.Duration, stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := er.SaveState(path); err != nil {
+			glog.Errorf("Could not save state: %v", err)
+		}
+	}, interval, stopCh)
+}
+
+// collectSeries returns the series of a counter or gauge. The state of
+// histograms and summaries is not persisted.
+func collectSeries(collector prometheus.Collector) []seriesState {
+	switch collector.(type) {
+	case *prometheus.CounterVec, *prometheus.GaugeVec:
+	default:
+		return nil
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collector.Collect(ch)
+		close(ch)
+	}()
+	var series []seriesState
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			glog.Errorf("Could not collect %s: %v", metric.Desc(), err)
+			continue
+		}
+		s := seriesState{Labels: make(map[string]string, len(m.GetLabel()))}
+		for _, label := range m.GetLabel() {
+			s.Labels[label.GetName()] = label.GetValue()
+		}
+		if m.Counter != nil {
+			s.Value = m.GetCounter().GetValue()
+		} else {
+			s.Value = m.GetGauge().GetValue()
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+func restoreSeries(collector prometheus.Collector, s seriesState) error {
+	switch vec := collector.(type) {
+	case *prometheus.CounterVec:
+		counter, err := vec.GetMetricWith(s.Labels)
+		if err != nil {
+			return err
+		}
+		counter.Add(s.Value)
+	case *prometheus.GaugeVec:
+		gauge, err := vec.GetMetricWith(s.Labels)
+		if err != nil {
+			return err
+		}
+		gauge.Set(s.Value)
+	default:
+		return fmt.Errorf("can't restore state of %T", collector)
+	}
+	return nil
+}
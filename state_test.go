@@ -0,0 +1,104 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestState(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: counter
+  labels:
+    reason: Reason
+- name: gauge
+  type: gauge
+  value: Count
+  labels:
+    reason: Reason
+`))
+	require.NoError(t, err)
+	newCollectors := func() {
+		kubernetesEventMetricVec = map[string]prometheus.Collector{
+			"counter": newMetricCollector(&config.Metrics[0], []string{"reason"}),
+			"gauge":   newMetricCollector(&config.Metrics[1], []string{"reason"}),
+		}
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	newCollectors()
+	router := &EventRouter{Config: config, processed: newLRUCache(10)}
+	require.NoError(t, router.LoadState(path), "a missing state file is not an error")
+	prometheusEvent(FilterMatch{Name: "counter", Labels: map[string]string{"reason": "BackOff"}, Value: 3})
+	prometheusEvent(FilterMatch{Name: "gauge", Labels: map[string]string{"reason": "BackOff"}, Value: 7})
+	router.processed.Add("1234", eventVersion{ResourceVersion: "1", Occurrences: 4})
+	router.processed.Add("5678", eventVersion{ResourceVersion: "2", Occurrences: 1})
+	require.NoError(t, router.SaveState(path))
+
+	newCollectors()
+	router = &EventRouter{Config: config, processed: newLRUCache(10)}
+	require.NoError(t, router.LoadState(path))
+	prometheusEvent(FilterMatch{Name: "counter", Labels: map[string]string{"reason": "BackOff"}, Value: 1})
+	require.InDelta(t, 4, counterValue(t, kubernetesEventMetricVec["counter"].(*prometheus.CounterVec), "BackOff"), 0)
+	require.Equal(t, []seriesState{{Labels: map[string]string{"reason": "BackOff"}, Value: 7}}, collectSeries(kubernetesEventMetricVec["gauge"]))
+	require.Equal(t, []lruEntry{
+		{key: "1234", value: eventVersion{ResourceVersion: "1", Occurrences: 4}},
+		{key: "5678", value: eventVersion{ResourceVersion: "2", Occurrences: 1}},
+	}, router.processed.Entries())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary files are left behind")
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	require.Error(t, router.LoadState(path))
+}
+
+func TestStateOccurrences(t *testing.T) {
+	config, err := NewConfig(bytes.NewBufferString(`metrics:
+- name: occurrences
+  accounting: occurrences
+  labels:
+    reason: Reason
+`))
+	require.NoError(t, err)
+	vec := newMetricCollector(&config.Metrics[0], []string{"reason"}).(*prometheus.CounterVec)
+	kubernetesEventMetricVec = map[string]prometheus.Collector{"occurrences": vec}
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	event := &v1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1234", ResourceVersion: "1"}, Reason: "BackOff", Count: 3, LastTimestamp: metav1.NewTime(time.Now())}
+	router := &EventRouter{Config: config, processed: newLRUCache(10)}
+	router.addEvent(event, false)
+	require.NoError(t, router.SaveState(path))
+
+	// the event occurred once more while the exporter was down
+	vec.Reset()
+	router = &EventRouter{Config: config, processed: newLRUCache(10)}
+	require.NoError(t, router.LoadState(path))
+	event = event.DeepCopy()
+	event.ResourceVersion = "2"
+	event.Count = 4
+	router.addEvent(event, true)
+	require.InDelta(t, 4, counterValue(t, vec, "BackOff"), 0)
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha1" //nolint:gosec // used for short label values, not for security
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+var (
+	templateFuncs = template.FuncMap{
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"trimPrefix":   func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix":   func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"regexReplace": newRegexReplace(nil),
+		"default":      defaultValue,
+		"truncate":     truncate,
+		"sha1":         sha1Hash,
+	}
+)
+
+// Object returns the pod the event refers to. It allows templates to use
+// `.Object` like the `Object.` label source. The pod is looked up once per
+// event and shared by all metrics.
+func (ctx *LookupContext) Object() (*v1.Pod, error) {
+	if ctx.objects == nil {
+		ctx.objects = &eventObjects{}
+	}
+	if !ctx.objects.podFetched {
+		ctx.objects.pod, ctx.objects.podErr = getPodObjectForEvent(ctx.Event)
+		ctx.objects.podFetched = true
+	}
+	return ctx.objects.pod, ctx.objects.podErr
+}
+
+func isTemplate(labelSpec string) bool {
+	return strings.Contains(labelSpec, "{{")
+}
+
+// newTemplateLookup creates the lookup for a label value given as a Go
+// template. The template is executed with the LookupContext, so it can refer
+// to `.Event`, `.Object`, `.Matches` and `.Item`.
+func newTemplateLookup(metric *MetricConfig, labelSpec string) (LookupFunc, error) {
+	tmpl, err := template.New(metric.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(labelSpec)
+	if err != nil {
+		return nil, fmt.Errorf("configuration for metric '%s' invalid: template '%s' invalid: %w", metric.Name, labelSpec, err)
+	}
+
+	// patterns given as constants are compiled once instead of per event
+	regexes := make(map[string]*regexp.Regexp)
+	for _, expr := range regexReplacePatterns(tmpl.Root) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("configuration for metric '%s' invalid: template '%s' invalid: %w", metric.Name, labelSpec, err)
+		}
+		regexes[expr] = re
+	}
+	tmpl.Funcs(template.FuncMap{"regexReplace": newRegexReplace(regexes)})
+
+	return func(ctx *LookupContext) (string, error) {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, ctx); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	}, nil
+}
+
+// newRegexReplace returns the regexReplace helper using the precompiled
+// regexes. Other patterns, e.g. taken from the event, are compiled per call.
+func newRegexReplace(regexes map[string]*regexp.Regexp) func(expr, replacement, s string) (string, error) {
+	return func(expr, replacement, s string) (string, error) {
+		re, found := regexes[expr]
+		if !found {
+			var err error
+			re, err = regexp.Compile(expr)
+			if err != nil {
+				return "", err
+			}
+		}
+		return re.ReplaceAllString(s, replacement), nil
+	}
+}
+
+// regexReplacePatterns returns the patterns of all calls of regexReplace in a
+// template which are given as string constants.
+func regexReplacePatterns(node parse.Node) []string {
+	var patterns []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			patterns = append(patterns, regexReplacePatterns(child)...)
+		}
+	case *parse.ActionNode:
+		patterns = regexReplacePatterns(n.Pipe)
+	case *parse.IfNode:
+		patterns = regexReplacePatterns(&n.BranchNode)
+	case *parse.RangeNode:
+		patterns = regexReplacePatterns(&n.BranchNode)
+	case *parse.WithNode:
+		patterns = regexReplacePatterns(&n.BranchNode)
+	case *parse.BranchNode:
+		patterns = append(regexReplacePatterns(n.Pipe), regexReplacePatterns(n.List)...)
+		patterns = append(patterns, regexReplacePatterns(n.ElseList)...)
+	case *parse.TemplateNode:
+		patterns = regexReplacePatterns(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			patterns = append(patterns, regexReplacePatterns(cmd)...)
+		}
+	case *parse.CommandNode:
+		if fn, ok := n.Args[0].(*parse.IdentifierNode); ok && fn.Ident == "regexReplace" && len(n.Args) > 1 {
+			if pattern, ok := n.Args[1].(*parse.StringNode); ok {
+				patterns = append(patterns, pattern.Text)
+			}
+		}
+		for _, arg := range n.Args {
+			patterns = append(patterns, regexReplacePatterns(arg)...)
+		}
+	case *parse.ChainNode:
+		patterns = regexReplacePatterns(n.Node)
+	}
+	return patterns
+}
+
+func defaultValue(def string, value interface{}) string {
+	if value == nil {
+		return def
+	}
+	if s := fmt.Sprint(value); s != "" {
+		return s
+	}
+	return def
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(n int, s string) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func sha1Hash(s string) string {
+	sum := sha1.Sum([]byte(s)) //nolint:gosec
+	return hex.EncodeToString(sum[:])
+}
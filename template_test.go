@@ -0,0 +1,121 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTemplateLabels(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: template
+  event_matcher:
+  - key: Message
+    expr: Volume (?P<volume>\S+) mount failed
+  labels:
+    location: '{{ .Event.InvolvedObject.Namespace }}/{{ .Object.Spec.NodeName }}'
+    volume: '{{ index .Matches.Message 1 | trimPrefix "vol-" | upper }}'
+    component: '{{ .Event.Source.Component | default "unknown" }}'
+    node: '{{ .Object.Spec.NodeName | regexReplace "\\.example\\.com$" "" | truncate 6 }}'
+    hash: '{{ .Event.Message | sha1 | truncate 8 }}'
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec:       v1.PodSpec{NodeName: "node-001.example.com"},
+	}
+	event := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+		Message:        "Volume vol-abc mount failed",
+	}
+
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fake.NewSimpleClientset(pod)})
+	require.Equal(t, []FilterMatch{
+		{Name: "template", Labels: map[string]string{
+			"location":  "test-namespace/node-001.example.com",
+			"volume":    "ABC",
+			"component": "unknown",
+			"node":      "node-0",
+			"hash":      "fa2b4c87",
+		}, Value: 1},
+	}, matches)
+}
+
+func TestObjectLookedUpOncePerEvent(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: template
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+  labels:
+    location: '{{ .Object.Spec.NodeName }}/{{ .Object.Spec.Containers | len }}'
+- name: container
+  event_matcher:
+  - key: Reason
+    expr: BackOff
+  labels:
+    node: Object.Spec.NodeName
+    container: Container.Name
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec:       v1.PodSpec{NodeName: "node-001", Containers: []v1.Container{{Name: "app"}}},
+	}
+	event := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, FieldPath: "spec.containers{app}"},
+		Reason:         "BackOff",
+	}
+
+	fakeClient := fake.NewSimpleClientset(pod)
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fakeClient})
+	require.Len(t, matches, 2)
+	require.Len(t, fakeClient.Actions(), 1, "The pod should be looked up once per event")
+}
+
+func TestConfigErrorTemplate(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: template
+  event_matcher:
+  - key: Message
+    expr: .*
+  labels:
+    broken: '{{ .Event.Message | unknownFunc }}'
+`)
+	_, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.ErrorContains(t, err, "configuration for metric 'template' invalid: template '{{ .Event.Message | unknownFunc }}' invalid")
+
+	// constant patterns are compiled with the configuration
+	testConfig = []byte(`metrics:
+- name: template
+  event_matcher:
+  - key: Message
+    expr: .*
+  labels:
+    broken: '{{ if .Event.Message }}{{ .Event.Message | regexReplace "(unclosed" "" }}{{ end }}'
+`)
+	_, err = NewConfig(bytes.NewBuffer(testConfig))
+	require.ErrorContains(t, err, "invalid: error parsing regexp: missing closing ): `(unclosed`")
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type TransformFunc = func(value string) string
+
+// TransformConfig is one step of the transformation pipeline of a label
+// value. Exactly one of its fields is set. Steps without arguments can be
+// given by their name alone, e.g. `- lowercase`.
+type TransformConfig struct {
+	Lowercase    bool `yaml:"lowercase"`
+	RegexReplace *struct {
+		Expr        string `yaml:"expr"`
+		Replacement string `yaml:"replacement"`
+	} `yaml:"regex_replace"`
+	Truncate int `yaml:"truncate"`
+	// length of the hex encoded SHA1 hash, 0 for the full hash
+	Hash *int `yaml:"hash"`
+	Map  *struct {
+		Values  map[string]string `yaml:"values"`
+		Default *string           `yaml:"default"`
+	} `yaml:"map"`
+	SanitizeUTF8 bool `yaml:"sanitize_utf8"`
+}
+
+func (t *TransformConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		switch name {
+		case "lowercase":
+			t.Lowercase = true
+		case "hash":
+			t.Hash = new(int)
+		case "sanitize_utf8":
+			t.SanitizeUTF8 = true
+		default:
+			return fmt.Errorf("unknown transformation '%s'", name)
+		}
+		return nil
+	}
+	type plain TransformConfig
+	return unmarshal((*plain)(t))
+}
+
+func newTransform(t TransformConfig) (TransformFunc, error) {
+	var steps []TransformFunc
+	if t.Lowercase {
+		steps = append(steps, strings.ToLower)
+	}
+	if t.RegexReplace != nil {
+		re, err := regexp.Compile(t.RegexReplace.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("regex_replace expression invalid: %w", err)
+		}
+		replacement := t.RegexReplace.Replacement
+		steps = append(steps, func(value string) string {
+			return re.ReplaceAllString(value, replacement)
+		})
+	}
+	if t.Truncate < 0 {
+		return nil, errors.New("truncate length must not be negative")
+	}
+	if t.Truncate > 0 {
+		length := t.Truncate
+		steps = append(steps, func(value string) string {
+			return truncate(length, value)
+		})
+	}
+	if t.Hash != nil {
+		length := *t.Hash
+		if length < 0 || length > 40 {
+			return nil, errors.New("hash length must be between 0 and 40")
+		}
+		steps = append(steps, func(value string) string {
+			if length == 0 {
+				return sha1Hash(value)
+			}
+			return sha1Hash(value)[:length]
+		})
+	}
+	if t.Map != nil {
+		values, def := t.Map.Values, t.Map.Default
+		steps = append(steps, func(value string) string {
+			if mapped, found := values[value]; found {
+				return mapped
+			}
+			if def != nil {
+				return *def
+			}
+			return value
+		})
+	}
+	if t.SanitizeUTF8 {
+		steps = append(steps, func(value string) string {
+			return strings.ToValidUTF8(value, "�")
+		})
+	}
+
+	if len(steps) != 1 {
+		return nil, fmt.Errorf("each transformation step must contain exactly one transformation, found %d", len(steps))
+	}
+	return steps[0], nil
+}
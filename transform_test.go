@@ -0,0 +1,110 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestLabelTransform(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: transform
+  event_matcher:
+  - key: Message
+    expr: image "(?P<image>[^"]+)"
+  labels:
+    image:
+      source: Message[image]
+      transform:
+      - regex_replace:
+          expr: '@sha256:[0-9a-f]+$'
+          replacement: ''
+      - truncate: 20
+    node:
+      source: Source.Host
+      transform:
+      - lowercase
+      - regex_replace:
+          expr: '\..*$'
+          replacement: ''
+    namespace:
+      source: InvolvedObject.Namespace
+      transform:
+      - map:
+          values:
+            kube-system: infra
+          default: other
+    hash:
+      source: InvolvedObject.Name
+      transform:
+      - hash: 8
+    reason:
+      source: Reason
+      transform:
+      - sanitize_utf8
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	testEvent := v1.Event{
+		InvolvedObject: v1.ObjectReference{Namespace: "kube-system", Name: "test-pod"},
+		Message:        `Failed to pull image "registry.example.com/team/app:1.0@sha256:0123456789abcdef"`,
+		Reason:         "Failed\xffPull",
+		Source:         v1.EventSource{Host: "Node-1.Example.Com"},
+	}
+	matches := LogEvent(&testEvent, &EventRouter{Config: config})
+
+	require.Equal(t, []FilterMatch{
+		{Name: "transform", Labels: map[string]string{
+			"image":     "registry.example.com",
+			"node":      "node-1",
+			"namespace": "infra",
+			"hash":      sha1Hash("test-pod")[:8],
+			"reason":    "Failed�Pull",
+		}, Value: 1},
+	}, matches)
+}
+
+func TestConfigErrorTransform(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: transform
+  event_matcher:
+  - key: Message
+    expr: .*
+  labels:
+    node:
+      source: Source.Host
+      transform:
+      - truncate: 10
+        lowercase: true
+`)
+	_, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.EqualError(t, err, "configuration for metric 'transform' invalid: transformation of label 'node' invalid: each transformation step must contain exactly one transformation, found 2")
+
+	testConfig = []byte(`metrics:
+- name: transform
+  labels:
+    node:
+      source: Source.Host
+      transform:
+      - uppercase
+`)
+	_, err = NewConfig(bytes.NewBuffer(testConfig))
+	require.ErrorContains(t, err, "unknown transformation 'uppercase'")
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FormatValue formats a value found in an event or a looked up object as
+// label value. Besides strings, this supports string-like named types (e.g.
+// types.UID), numbers, booleans, timestamps and resource quantities.
+func FormatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case metav1.Time:
+		return formatTime(v.Time), nil
+	case metav1.MicroTime:
+		return formatTime(v.Time), nil
+	case time.Time:
+		return formatTime(v), nil
+	case metav1.Duration:
+		return v.Duration.String(), nil
+	case time.Duration:
+		return v.String(), nil
+	case resource.Quantity:
+		return v.String(), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	}
+
+	return "", fmt.Errorf("value of type %T can't be used as label value", value)
+}
+
+// formatTime formats t with the layout given by the -time-layout flag. Unset
+// timestamps yield an empty value.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timeLayout)
+}
+
+// splitPath splits a path like `Spec.Containers[0].Image` into the segments
+// `Spec`, `Containers`, `[0]` and `Image`. Dots within brackets are part of
+// the index, so map keys like `app.kubernetes.io/name` can be used.
+func splitPath(path string) []string {
+	var segments []string
+	start := 0
+	inBrackets := false
+	for i, c := range path {
+		switch {
+		case c == '[' && !inBrackets:
+			if i > start {
+				segments = append(segments, path[start:i])
+			}
+			start = i
+			inBrackets = true
+		case c == ']' && inBrackets:
+			segments = append(segments, path[start:i+1])
+			start = i + 1
+			inBrackets = false
+		case c == '.' && !inBrackets:
+			if i > start {
+				segments = append(segments, path[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(path) {
+		segments = append(segments, path[start:])
+	}
+	return segments
+}
+
+// isIndexablePath reports whether path refers to a list or map within values
+// of type t, so that it can be followed by an index.
+func isIndexablePath(t reflect.Type, path string) bool {
+	for _, segment := range splitPath(path) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if strings.HasPrefix(segment, "[") {
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+				continue
+			}
+			return false
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		field, ok := t.FieldByName(segment)
+		if !ok || !field.IsExported() {
+			return false
+		}
+		t = field.Type
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// indirect dereferences pointers and interfaces until it reaches a value.
+func indirect(value reflect.Value) (reflect.Value, error) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return value, errors.New("value is nil")
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return value, errors.New("value is nil")
+	}
+	return value, nil
+}
+
+// indexValue selects an element of a slice or array by position, or an
+// element of a map with string-like keys by key.
+func indexValue(value reflect.Value, index string) (reflect.Value, error) {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			return value, fmt.Errorf("invalid list index '%s'", index)
+		}
+		if i < 0 || i >= value.Len() {
+			return value, fmt.Errorf("list index %d out of range", i)
+		}
+		return value.Index(i), nil
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return value, errors.New("map keys are not strings")
+		}
+		element := value.MapIndex(reflect.ValueOf(index).Convert(value.Type().Key()))
+		if !element.IsValid() {
+			return value, fmt.Errorf("key '%s' not found", index)
+		}
+		return element, nil
+	}
+	return value, errors.New("value is neither a list nor a map")
+}
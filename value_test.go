@@ -0,0 +1,117 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFormatValue(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	event := &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{UID: "1234-abcd", Labels: map[string]string{"app.kubernetes.io/name": "app"}},
+		Count:          42,
+		LastTimestamp:  metav1.NewTime(timestamp),
+		Type:           "Warning",
+		InvolvedObject: v1.ObjectReference{Kind: "Pod"},
+	}
+
+	for key, expected := range map[string]string{
+		"Count":                          "42",
+		"UID":                            "1234-abcd",
+		"ObjectMeta.UID":                 "1234-abcd",
+		"LastTimestamp":                  "2024-05-01T12:30:00Z",
+		"FirstTimestamp":                 "",
+		"Labels[app.kubernetes.io/name]": "app",
+		"InvolvedObject.Kind":            "Pod",
+	} {
+		value, err := GetValueFromStruct(event, key)
+		require.NoError(t, err, key)
+		require.Equal(t, expected, value, key)
+	}
+
+	_, err := GetValueFromStruct(event, "InvolvedObject")
+	require.EqualError(t, err, "value of type v1.ObjectReference can't be used as label value")
+	_, err = GetValueFromStruct(event, "DeletionGracePeriodSeconds")
+	require.EqualError(t, err, "value is nil")
+	_, err = GetValueFromStruct(event, "Labels[missing]")
+	require.EqualError(t, err, "extracting value failed at [missing], index 1: key 'missing' not found")
+
+	quantity, err := FormatValue(resource.MustParse("512Mi"))
+	require.NoError(t, err)
+	require.Equal(t, "512Mi", quantity)
+	boolean, err := FormatValue(true)
+	require.NoError(t, err)
+	require.Equal(t, "true", boolean)
+}
+
+func TestObjectIndexedPath(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: limits
+  event_matcher:
+  - key: Reason
+    expr: OOMKilling
+  labels:
+    memory: Object.Spec.Containers[0].Resources.Limits[memory]
+    count: Count
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name:      "app",
+			Resources: v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}},
+		}}},
+	}
+	event := v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+		Reason:         "OOMKilling",
+		Count:          3,
+	}
+
+	matches := LogEvent(&event, &EventRouter{Config: config, kubeClient: fake.NewSimpleClientset(pod)})
+	require.Equal(t, []FilterMatch{
+		{Name: "limits", Labels: map[string]string{"memory": "1Gi", "count": "3"}, Value: 1},
+	}, matches)
+}
+
+func TestEventIndexedPath(t *testing.T) {
+	testConfig := []byte(`metrics:
+- name: annotated
+  event_matcher:
+  - key: Reason
+    expr: (Back)(Off)
+  labels:
+    team: ObjectMeta.Annotations[team]
+    reason: Reason[2]
+`)
+	config, err := NewConfig(bytes.NewBuffer(testConfig))
+	require.NoError(t, err, "There should be no error while unmarshaling config")
+
+	event := v1.Event{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"team": "storage"}}, Reason: "BackOff"}
+	require.Equal(t, []FilterMatch{
+		{Name: "annotated", Labels: map[string]string{"team": "storage", "reason": "Off"}, Value: 1},
+	}, LogEvent(&event, &EventRouter{Config: config}))
+}
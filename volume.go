@@ -0,0 +1,174 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	VolumeVirtualTypePrefix = "Volume."
+)
+
+var (
+	// matches e.g. `MountVolume.SetUp failed for volume "pvc-1234" : ...`
+	volumeNameRE = regexp.MustCompile(`volume "([^"]+)"`)
+	// matches e.g. `Unable to attach or mount volumes: unmounted volumes=[data cache], ...`
+	unmountedVolumesRE = regexp.MustCompile(`unmounted volumes=\[([^\]]*)\]`)
+)
+
+// VolumeInfo describes the storage chain (PVC -> PV -> StorageClass -> driver)
+// behind a volume event. Its fields are exposed as `Volume.<Field>` labels.
+type VolumeInfo struct {
+	PVC          string
+	PV           string
+	StorageClass string
+	Driver       string
+}
+
+// volumeInfo returns the storage chain behind the event. It is resolved once
+// per event and shared by all metrics.
+func (ctx *LookupContext) volumeInfo() (*VolumeInfo, error) {
+	if ctx.objects == nil {
+		ctx.objects = &eventObjects{}
+	}
+	if !ctx.objects.volumeFetched {
+		ctx.objects.volume, ctx.objects.volumeErr = getVolumeInfoForEvent(ctx)
+		ctx.objects.volumeFetched = true
+	}
+	return ctx.objects.volume, ctx.objects.volumeErr
+}
+
+func getVolumeInfoForEvent(ctx *LookupContext) (*VolumeInfo, error) {
+	event := ctx.Event
+	switch event.InvolvedObject.Kind {
+	case "PersistentVolumeClaim":
+		pvc, err := eventRouter.kubeClient.CoreV1().PersistentVolumeClaims(event.InvolvedObject.Namespace).Get(context.TODO(), event.InvolvedObject.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return resolveVolumeChain(pvc)
+	case "PersistentVolume":
+		pv, err := eventRouter.kubeClient.CoreV1().PersistentVolumes().Get(context.TODO(), event.InvolvedObject.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		info := &VolumeInfo{PV: pv.Name, StorageClass: pv.Spec.StorageClassName}
+		if pv.Spec.ClaimRef != nil {
+			info.PVC = pv.Spec.ClaimRef.Name
+		}
+		resolveVolumeDriver(info, pv)
+		return info, nil
+	}
+
+	pod, err := ctx.Object()
+	if err != nil {
+		return nil, err
+	}
+	names := volumeNamesFromMessage(event.Message)
+
+	for _, volume := range pod.Spec.Volumes {
+		var claimName string
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		case volume.Ephemeral != nil:
+			// generic ephemeral volumes are backed by a PVC named <pod>-<volume>
+			claimName = pod.Name + "-" + volume.Name
+		default:
+			continue
+		}
+
+		// the message refers to volumes either by their name in the pod spec or by the PV name,
+		// so claims of other volumes which no longer exist are of no interest
+		named := names[volume.Name]
+		pvc, err := eventRouter.kubeClient.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(context.TODO(), claimName, metav1.GetOptions{})
+		if err != nil {
+			if !named && apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if len(names) > 0 && !named && !names[pvc.Spec.VolumeName] {
+			continue
+		}
+		return resolveVolumeChain(pvc)
+	}
+
+	return nil, errors.New("no persistent volume claim found for event")
+}
+
+func volumeNamesFromMessage(message string) map[string]bool {
+	names := make(map[string]bool)
+	for _, match := range volumeNameRE.FindAllStringSubmatch(message, -1) {
+		names[match[1]] = true
+	}
+	if match := unmountedVolumesRE.FindStringSubmatch(message); match != nil {
+		for _, name := range strings.Fields(match[1]) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func resolveVolumeChain(pvc *v1.PersistentVolumeClaim) (*VolumeInfo, error) {
+	info := &VolumeInfo{PVC: pvc.Name, PV: pvc.Spec.VolumeName}
+	if pvc.Spec.StorageClassName != nil {
+		info.StorageClass = *pvc.Spec.StorageClassName
+	}
+
+	var pv *v1.PersistentVolume
+	if info.PV != "" {
+		var err error
+		pv, err = eventRouter.kubeClient.CoreV1().PersistentVolumes().Get(context.TODO(), info.PV, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if info.StorageClass == "" {
+			info.StorageClass = pv.Spec.StorageClassName
+		}
+	}
+
+	resolveVolumeDriver(info, pv)
+	return info, nil
+}
+
+// resolveVolumeDriver prefers the CSI driver of the bound PV and falls back to
+// the provisioner of the StorageClass, e.g. for claims which are still pending.
+// The driver is left empty if the StorageClass can't be looked up, as the rest
+// of the chain is still of use.
+func resolveVolumeDriver(info *VolumeInfo, pv *v1.PersistentVolume) {
+	if pv != nil && pv.Spec.CSI != nil {
+		info.Driver = pv.Spec.CSI.Driver
+		return
+	}
+	if info.StorageClass == "" {
+		return
+	}
+	sc, err := eventRouter.kubeClient.StorageV1().StorageClasses().Get(context.TODO(), info.StorageClass, metav1.GetOptions{})
+	if err != nil {
+		glog.Warningf("Could not get driver of storage class '%s': %v", info.StorageClass, err)
+		return
+	}
+	info.Driver = sc.Provisioner
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// number of buckets a window is divided into
+const windowBuckets = 60
+
+// windowVec is a collector which sums up the values of matches per label set
+// within a sliding window and exports the sums as gauges. Each series keeps a
+// ring of time buckets, so matches leave the window bucket by bucket. Series
+// without matches in the window are removed.
+type windowVec struct {
+	desc        *prometheus.Desc
+	labels      []string
+	bucketWidth time.Duration
+	now         func() time.Time
+
+	mu     sync.Mutex
+	series map[string]*windowSeries
+}
+
+type windowSeries struct {
+	labelValues []string
+	buckets     [windowBuckets]float64
+	// number of the newest bucket, counted in bucket widths since the epoch
+	newest int64
+}
+
+func newWindowVec(name, help string, labels []string, window time.Duration) *windowVec {
+	bucketWidth := window / windowBuckets
+	if bucketWidth <= 0 {
+		bucketWidth = 1
+	}
+	return &windowVec{
+		desc:        prometheus.NewDesc(name, help, labels, nil),
+		labels:      labels,
+		bucketWidth: bucketWidth,
+		now:         time.Now,
+		series:      make(map[string]*windowSeries),
+	}
+}
+
+// Add adds value to the series with the given labels.
+func (v *windowVec) Add(labels map[string]string, value float64) error {
+	if value < 0 {
+		return fmt.Errorf("window can't be increased by negative value %g", value)
+	}
+	if len(labels) != len(v.labels) {
+		return fmt.Errorf("expected labels %v, got %v", v.labels, labels)
+	}
+	labelValues := make([]string, len(v.labels))
+	for i, name := range v.labels {
+		value, found := labels[name]
+		if !found {
+			return fmt.Errorf("label '%s' missing", name)
+		}
+		labelValues[i] = value
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	bucket := v.bucket()
+	series, found := v.series[key]
+	if !found {
+		series = &windowSeries{labelValues: labelValues, newest: bucket}
+		v.series[key] = series
+	}
+	series.advance(bucket)
+	series.buckets[bucket%windowBuckets] += value
+	return nil
+}
+
+func (v *windowVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+func (v *windowVec) Collect(ch chan<- prometheus.Metric) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	bucket := v.bucket()
+	for key, series := range v.series {
+		series.advance(bucket)
+		var sum float64
+		for _, value := range series.buckets {
+			sum += value
+		}
+		if sum == 0 {
+			delete(v.series, key)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(v.desc, prometheus.GaugeValue, sum, series.labelValues...)
+	}
+}
+
+func (v *windowVec) bucket() int64 {
+	return v.now().UnixNano() / int64(v.bucketWidth)
+}
+
+// advance clears the buckets which left the window since the newest bucket.
+func (s *windowSeries) advance(bucket int64) {
+	if bucket-s.newest >= windowBuckets {
+		s.buckets = [windowBuckets]float64{}
+	} else {
+		for b := s.newest + 1; b <= bucket; b++ {
+			s.buckets[b%windowBuckets] = 0
+		}
+	}
+	if bucket > s.newest {
+		s.newest = bucket
+	}
+}
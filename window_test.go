@@ -0,0 +1,63 @@
+// Copyright 2024 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// windowValues collects the current sums of a windowVec by reason label.
+func windowValues(t *testing.T, vec *windowVec) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 10)
+	vec.Collect(ch)
+	close(ch)
+	values := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+		values[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestWindowVec(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	vec := newWindowVec("backoff_last_15m", "help", []string{"reason"}, 15*time.Minute)
+	vec.now = func() time.Time { return now }
+
+	require.NoError(t, vec.Add(map[string]string{"reason": "BackOff"}, 1))
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, vec.Add(map[string]string{"reason": "BackOff"}, 2))
+	require.NoError(t, vec.Add(map[string]string{"reason": "Failed"}, 1))
+	require.Equal(t, map[string]float64{"BackOff": 3, "Failed": 1}, windowValues(t, vec))
+
+	// the first match leaves the window
+	now = now.Add(11 * time.Minute)
+	require.Equal(t, map[string]float64{"BackOff": 2, "Failed": 1}, windowValues(t, vec))
+
+	// series disappear once their window is empty
+	now = now.Add(time.Hour)
+	require.Empty(t, windowValues(t, vec))
+	require.Empty(t, vec.series)
+
+	require.Error(t, vec.Add(map[string]string{"node": "node-1"}, 1))
+	require.Error(t, vec.Add(map[string]string{"reason": "BackOff"}, -1))
+}